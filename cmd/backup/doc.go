@@ -0,0 +1,135 @@
+// 2026-10-18 adbr
+
+/*
+Program backup wykonuje zadania backupu opisane w pliku
+konfiguracyjnym. Każde zadanie jest wywołaniem programu snapshot dla
+jednego backupowanego katalogu. Niezależne zadania są wykonywane
+równolegle, np. snapshoty filesystemów / i /home, które są na różnych
+dyskach.
+
+Sposób użycia:
+	backup [opcje] [zadanie...]
+	backup [opcje] daemon [zadanie...]
+Opcje:
+	-config filename
+		plik konfiguracyjny (domyślnie: "/etc/backup.conf")
+	-jobs n
+		maksymalna liczba równolegle wykonywanych zadań
+		(domyślnie: wartość jobs z pliku konfiguracyjnego)
+	-device-jobs n
+		maksymalna liczba zadań równolegle zapisujących na to
+		samo urządzenie (domyślnie: wartość device-jobs z pliku
+		konfiguracyjnego)
+	-n	wyświetlenie poleceń (i harmonogramów w trybie
+		daemon) bez ich wykonywania
+	-h	sposób użycia
+	-help	dokumentacja
+
+Bez argumentów wykonywane są wszystkie zadania z pliku
+konfiguracyjnego, a z argumentami - tylko zadania o podanych nazwach.
+Jeśli pierwszym argumentem jest "daemon", to program działa w tle i
+wykonuje zadania według ich harmonogramów (zob. Tryb daemon).
+
+Plik konfiguracyjny składa się z wierszy "klucz = wartość"; wiersze
+puste i zaczynające się od '#' są pomijane. Wiersz "[nazwa]" rozpoczyna
+opis zadania. Klucze przed pierwszym zadaniem są ustawieniami
+globalnymi:
+
+	jobs		maksymalna liczba równoległych zadań (domyślnie: 1)
+	device-jobs	maksymalna liczba równoległych zadań zapisujących
+			na jedno urządzenie (domyślnie: 1)
+	snapshot	polecenie snapshot (domyślnie: "snapshot")
+	options		opcje programu snapshot dla wszystkich zadań,
+			np. "-keep=30"
+	retry		czas, po którym tryb daemon ponawia nieudane
+			zadanie (domyślnie: 1h)
+	lockdir		katalog plików blokad zadań (domyślnie:
+			/var/run dla roota, a dla innych użytkowników
+			$XDG_RUNTIME_DIR; inny katalog musi być taki, w
+			którym tylko użytkownik może tworzyć pliki)
+
+Klucze zadania:
+
+	src		backupowany katalog (opcja -src programu snapshot)
+	dst		katalog ze snapshotami (opcja -dst)
+	exclude		wzorce ignorowanych plików (opcja -exclude)
+	options		dodatkowe opcje programu snapshot, np.
+			"-logfile=/var/log/backup-home.log" (plik logu
+			powinien być osobny dla każdego zadania, bo
+			zadania mogą być wykonywane równolegle)
+	device		nazwa urządzenia docelowego dla limitu
+			device-jobs (domyślnie: numer urządzenia
+			filesystemu z katalogiem dst lub nazwa hosta dla
+			zdalnego dst)
+	schedule	harmonogram zadania dla trybu daemon
+
+Przykład:
+
+	jobs = 2
+	device-jobs = 1
+
+	[root]
+	src = /
+	dst = /backup/root
+	options = -logfile=/home/adbr/lib/log/backup-root.log
+
+	[home]
+	src = /home
+	dst = /backup/home
+	exclude = adbr/tmp/*,.cache/*
+	options = -logfile=/home/adbr/lib/log/backup-home.log
+	schedule = 30 3 * * *
+
+Zadania są uruchamiane w kolejności z pliku konfiguracyjnego, ale
+zadanie czeka, jeśli wykonuje się już -jobs zadań albo -device-jobs
+zadań zapisujących na to samo urządzenie. Limit device-jobs chroni
+dysk z backupami przed zbyt wieloma równoległymi snapshotami, gdy
+backupowane katalogi są na różnych dyskach, a snapshoty na jednym.
+
+Każdy wiersz wyjścia zadania jest poprzedzony nazwą zadania w
+nawiasach kwadratowych, np. "[home] snapshot: ...". Na końcu jest
+wypisywane podsumowanie: status i czas trwania każdego zadania.
+Program kończy się kodem 1, jeśli któreś zadanie zakończyło się
+błędem.
+
+To samo zadanie nigdy nie jest wykonywane dwa razy jednocześnie, także
+przez różne procesy programu backup: zadanie zakłada blokadę (flock(2))
+na pliku backup-nazwa.lock w katalogu lockdir. Plik blokady nie może
+być symlinkiem. Zadanie, którego blokada jest zajęta, kończy się
+błędem "zadanie jest już wykonywane".
+
+Tryb daemon
+
+W trybie daemon program wykonuje zadania z kluczem schedule (albo
+podane jako argumenty) według ich harmonogramów, aż do otrzymania
+sygnału SIGINT lub SIGTERM. Wtedy czeka na zakończenie wykonywanych
+zadań. Harmonogram ma postać:
+
+	every czas		co podany czas, np. "every 6h", "every 1h30m"
+	@hourly, @daily,	co godzinę, codziennie, co tydzień (w
+	@weekly, @monthly	niedzielę), co miesiąc (pierwszego dnia);
+				zawsze o północy lub o pełnej godzinie
+	m h dom mon dow		jak w crontab(5): minuta, godzina, dzień
+				miesiąca, miesiąc i dzień tygodnia (0 lub
+				7 - niedziela); pola mogą zawierać "*",
+				listy, zakresy i kroki, np. "0 9-17/2 * * 1-5"
+
+Czasem ostatniego wykonania zadania jest czas najnowszego kompletnego
+snapshotu w katalogu dst, więc program pamięta go między
+uruchomieniami i uwzględnia snapshoty utworzone poza trybem daemon.
+Zadanie jest wykonywane, gdy minie najbliższy po tym czasie termin z
+harmonogramu. Tak jak w anacron(8), zadanie pominięte, bo komputer był
+wyłączony lub uśpiony, jest wykonywane zaraz (w ciągu minuty) po
+uruchomieniu programu lub wybudzeniu komputera - raz, niezależnie od
+liczby pominiętych terminów. Zadanie bez snapshotów jest wykonywane od
+razu. Nieudane zadanie jest ponawiane po czasie retry, a zadanie
+wykonywane przez inny proces (zajęta blokada) - w następnym terminie
+z harmonogramu. Limity jobs i device-jobs obowiązują tak samo jak przy
+zwykłym wykonaniu.
+
+Przykład uruchomienia w tle z komunikatami w pliku:
+
+	$ backup -config=/home/adbr/bin/backup.conf daemon \
+		>>/home/adbr/lib/log/backup-daemon.log 2>&1 &
+*/
+package main
@@ -14,6 +14,90 @@ są zwiększane, np:
 	...
 
 Maksymalną liczbę zarchiwizowanych plików z logami określa opcja -num.
+Przy rotacji archiwa, które miałyby numer -num lub większy, są
+usuwane - także archiwa pozostałe po wcześniejszych rotacjach z
+większą wartością -num.
+
+Opcja -maxage powoduje usunięcie archiwów starszych niż podany czas
+(liczony od ostatniej modyfikacji archiwum, czyli od ostatniego zapisu
+do logu przed jego rotacją), a opcja -maxtotal - usunięcie
+najstarszych archiwów, dopóki łączny rozmiar archiwów jest większy niż
+podana wartość. Te ograniczenia są sprawdzane przy każdym
+uruchomieniu programu, także jeśli plik nie jest rotowany. Każde
+usunięcie archiwum jest wypisywane (także bez opcji -v) razem z
+powodem usunięcia.
+
+Program, który trzyma plik z logami otwarty, po zmianie nazwy pliku
+pisze dalej do pliku log.0. Jeśli nie można go powiadomić o rotacji,
+można użyć opcji -copytruncate: zawartość pliku log jest kopiowana do
+log.0 (kopia jest zapisywana na dysk), a potem plik log jest obcinany
+do zera bez zamykania go przez program piszący. Dane zapisane do logu
+między skopiowaniem a obcięciem pliku są tracone - program wypisuje
+ich liczbę bajtów, jeśli takie zapisy wystąpiły. Program piszący
+powinien otwierać log w trybie O_APPEND, inaczej po obcięciu pisze
+dalej od poprzedniej pozycji, a początek pliku jest wypełniony
+zerami.
+
+Zamiast -copytruncate lepiej powiadomić program piszący o rotacji, żeby
+otworzył log ponownie: z opcją -pidfile po rotacji do procesu o
+numerze zapisanym w podanym pliku jest wysyłany sygnał z opcji -signal
+(większość demonów otwiera logi ponownie po SIGHUP lub SIGUSR1). Opcja
+-postrotate podaje polecenie powłoki wykonywane po rotacji (np.
+"rcctl reload httpd"), a -prerotate - polecenie wykonywane przed
+rotacją (jego błąd przerywa rotację). Polecenia dostają nazwę pliku z
+logami jako argument $1 i są zabijane po przekroczeniu czasu z opcji
+-timeout. Ich wyjście jest wypisywane z prefiksem "logrotate:
+prerotate:" lub "logrotate: postrotate:". Program piszący może
+jeszcze chwilę po powiadomieniu pisać do log.0, dlatego z opcją
+-compress zaleca się też -delaycompress. Przykład:
+
+	logrotate -size=10000000 -num=10 -compress -delaycompress \
+		-pidfile=/var/run/syslog.pid -signal=HUP /var/log/messages
+
+Opcja -interval włącza rotację według czasu. Z wartościami hourly,
+daily, weekly i monthly plik jest rotowany przy pierwszym uruchomieniu
+programu w nowej godzinie, dniu, tygodniu (tydzień zaczyna się w
+poniedziałek) lub miesiącu, np. uruchamiany co godzinę z cron(8) z
+opcją -interval=daily rotuje log raz dziennie, krótko po północy.
+Wartość będąca czasem, np. "36h", oznacza rotację, jeśli od ostatniej
+rotacji minął ten czas. Plik jest rotowany, jeśli jest większy niż
+-size LUB minął czas rotacji, np:
+
+	-interval=daily -minsize=1
+		codziennie, ale tylko jeśli log nie jest pusty
+	-interval=weekly -minsize=1000000
+		co tydzień, ale tylko jeśli log ma co najmniej 1MB
+	-size=10000000 -interval=720h
+		po przekroczeniu 10MB albo po 30 dniach
+
+Czas ostatniej rotacji jest zapisywany w pliku stanu (nie jest
+odczytywany z czasu modyfikacji pliku, który zmienia każdy zapis do
+logu). Plik stanu zawiera wiersze "czas plik" i może być wspólny dla
+wielu logów (opcja -state). Przy pierwszym uruchomieniu z opcją
+-interval, gdy w pliku stanu nie ma jeszcze czasu rotacji logu,
+zapisywany jest bieżący czas, a plik nie jest rotowany według czasu.
+
+Z opcją -compress zarchiwizowane pliki są kompresowane w formacie z
+opcji -codec (np. log.0 -> log.0.gz), a z opcją -delaycompress plik
+log.0 pozostaje nieskompresowany do następnej rotacji (np. dlatego, że
+program piszący do logu może go jeszcze mieć otwartego). Kompresowane
+są też nieskompresowane archiwa z wcześniejszych rotacji. Plik
+skompresowany jest zapisywany do pliku tymczasowego .log.0.gz.tmp,
+zapisywany na dysk i dopiero potem jego nazwa jest zmieniana na
+docelową, a plik źródłowy jest usuwany - więc po przerwaniu programu
+nie ginie żaden log.
+
+Formaty kompresji i rozszerzenia plików:
+
+	gzip	.gz	(kompresja w programie)
+	zstd	.zst	(polecenie zstd(1))
+	xz	.xz	(polecenie xz(1))
+	bzip2	.bz2	(polecenie bzip2(1))
+
+Archiwa w każdym z tych formatów są rozpoznawane niezależnie od opcji
+-codec, więc po zmianie formatu starsze archiwa są dalej rotowane i
+numerowane razem z nowymi, np. log.0.zst, log.1.zst, log.2.gz. Opcja
+-codec pozwala wybrać format osobno dla każdego logu.
 
 Sposób użycia:
 	logrotate [opcje] logfile
@@ -21,11 +105,52 @@ Opcje:
 	-num int
 		maksymalna liczba archiwizowanych plików (domyślnie:
 		0, czyli bez ograniczenia)
+	-maxage duration
+		maksymalny wiek archiwum, np. "720h" (domyślnie: 0,
+		czyli bez ograniczenia)
+	-maxtotal int
+		maksymalny łączny rozmiar (w bajtach) archiwów
+		(domyślnie: 0, czyli bez ograniczenia)
 	-size int
 		wielkość (w bajtach) archiwizowanego pliku, jeśli
 		rozmiar pliku logfile jest większy niż -size to plik
 		jest archiwizowany (domyślnie: 0, czyli bez
 		ograniczenia)
+	-interval string
+		rotacja według czasu: hourly, daily, weekly, monthly
+		albo czas od ostatniej rotacji, np. "36h" (domyślnie:
+		"", czyli bez rotacji według czasu)
+	-minsize int
+		minimalna wielkość (w bajtach) pliku rotowanego według
+		czasu (domyślnie: 0)
+	-state filename
+		plik z czasami ostatnich rotacji (domyślnie:
+		".logfile.state" w katalogu pliku logfile)
+	-copytruncate
+		rotacja przez skopiowanie pliku logfile do logfile.0 i
+		obcięcie pliku logfile zamiast zmiany jego nazwy
+	-compress
+		kompresja zarchiwizowanych plików
+	-codec string
+		format kompresji: gzip, zstd, xz lub bzip2 (włącza
+		-compress) (domyślnie: "gzip")
+	-delaycompress
+		kompresja pliku logfile.0 dopiero przy następnej
+		rotacji (razem z -compress)
+	-pidfile filename
+		plik z numerem procesu programu piszącego do logu, do
+		którego po rotacji jest wysyłany sygnał -signal
+	-signal string
+		sygnał wysyłany do procesu z -pidfile: HUP, USR1 lub
+		USR2 (domyślnie: "HUP")
+	-prerotate string
+		polecenie powłoki wykonywane przed rotacją; jeśli
+		zakończy się błędem, to rotacja jest przerywana
+	-postrotate string
+		polecenie powłoki wykonywane po rotacji
+	-timeout duration
+		maksymalny czas wykonania poleceń -prerotate i
+		-postrotate (domyślnie: 1m)
 	-v	wyświetlanie komunikatów (verbose)
 	-h	sposób użycia
 	-help	dokumentacja
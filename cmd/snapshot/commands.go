@@ -0,0 +1,671 @@
+// 2026-10-18 adbr
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adbr/backup/internal/job"
+	"github.com/adbr/backup/internal/snapshot"
+)
+
+// Zmienna commands zawiera polecenia programu wywoływane przez
+// 'snapshot polecenie [opcje] [argumenty]'. Funkcja polecenia
+// dostaje argumenty występujące po nazwie polecenia.
+var commands = map[string]func(args []string) error{
+	"pin":       pinCommand,
+	"unpin":     unpinCommand,
+	"delete":    deleteCommand,
+	"usage":     usageCommand,
+	"prune":     pruneCommand,
+	"export":    exportCommand,
+	"import":    importCommand,
+	"history":   historyCommand,
+	"replicate": replicateCommand,
+	"fsck":      fsckCommand,
+	"check":     checkCommand,
+}
+
+// runCommand wykonuje polecenie name z argumentami args i kończy
+// program z odpowiednim kodem wyjścia.
+func runCommand(name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "snapshot: nieznane polecenie %q\n", name)
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+	err := cmd(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snapshot: %s: %s\n", name, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// newFlagSet tworzy zbiór opcji dla polecenia name. Błąd parsowania
+// opcji kończy program z kodem 2. Każde polecenie ma opcję -naming,
+// bo nazwy snapshotów we własnym formacie są rozpoznawane tylko z tą
+// opcją.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usageText)
+	}
+	namingFlag(fs)
+	return fs
+}
+
+// namingFlag definiuje w fs opcję -naming ustawiającą format nazw
+// snapshotów (snapshot.SetNaming).
+func namingFlag(fs *flag.FlagSet) {
+	fs.Var(namingValue{}, "naming", "")
+}
+
+// Typ namingValue jest wartością opcji -naming.
+type namingValue struct{}
+
+func (namingValue) String() string {
+	return "local"
+}
+
+func (namingValue) Set(s string) error {
+	return snapshot.SetNaming(s)
+}
+
+// parseArgs parsuje opcje z args i zwraca pozostałe argumenty. W
+// odróżnieniu od fs.Parse opcje mogą występować także po
+// argumentach, np.: 'snapshot pin 2015-02-10T18:07:39 -reason=...'.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	return rest
+}
+
+// requireDst kończy program z kodem 2 jeśli brakuje opcji -dst.
+func requireDst(name, dst string) {
+	if dst == "" {
+		fmt.Fprintf(os.Stderr, "snapshot: %s: brakuje opcji -dst\n", name)
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+}
+
+// requireArgs kończy program z kodem 2 jeśli liczba argumentów
+// polecenia name jest różna od n.
+func requireArgs(name string, args []string, n int) {
+	if len(args) != n {
+		fmt.Fprintf(os.Stderr, "snapshot: %s: błędna liczba argumentów\n", name)
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+}
+
+// pinCommand przypina snapshot: 'snapshot pin -dst=directory
+// [-reason=string] [-readonly] timestamp'. Bez argumentu timestamp
+// wyświetla listę przypiętych snapshotów.
+func pinCommand(args []string) error {
+	fs := newFlagSet("pin")
+	dst := fs.String("dst", "", "")
+	reason := fs.String("reason", "", "")
+	readonly := fs.Bool("readonly", false, "")
+	args = parseArgs(fs, args)
+	requireDst("pin", *dst)
+
+	if len(args) == 0 {
+		pins, err := snapshot.Pins(*dst)
+		if err != nil {
+			return err
+		}
+		for _, p := range pins {
+			ro := ""
+			if p.ReadOnly {
+				ro = " (readonly)"
+			}
+			fmt.Printf("%s%s\t%s\n", p.Snapshot, ro, p.Reason)
+		}
+		return nil
+	}
+	requireArgs("pin", args, 1)
+	return snapshot.PinSnapshot(*dst, args[0], *reason, *readonly)
+}
+
+// unpinCommand odpina snapshot: 'snapshot unpin -dst=directory
+// timestamp'.
+func unpinCommand(args []string) error {
+	fs := newFlagSet("unpin")
+	dst := fs.String("dst", "", "")
+	args = parseArgs(fs, args)
+	requireDst("unpin", *dst)
+	requireArgs("unpin", args, 1)
+	return snapshot.Unpin(*dst, args[0])
+}
+
+// deleteCommand usuwa snapshot: 'snapshot delete -dst=directory
+// timestamp'.
+func deleteCommand(args []string) error {
+	fs := newFlagSet("delete")
+	dst := fs.String("dst", "", "")
+	args = parseArgs(fs, args)
+	requireDst("delete", *dst)
+	requireArgs("delete", args, 1)
+	return snapshot.Delete(*dst, args[0])
+}
+
+// usageCommand wyświetla zajętość miejsca przez snapshoty z
+// uwzględnieniem hard linków: 'snapshot usage -dst=directory'.
+func usageCommand(args []string) error {
+	fs := newFlagSet("usage")
+	dst := fs.String("dst", "", "")
+	args = parseArgs(fs, args)
+	requireDst("usage", *dst)
+	requireArgs("usage", args, 0)
+
+	u, err := snapshot.DiskUsage(*dst)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "snapshot\twyłączne\twspółdzielone\tzwolni\t\n")
+	var exclusive, freed int64
+	for _, s := range u.Snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", s.Snapshot,
+			formatSize(s.Exclusive), formatSize(s.Shared), formatSize(s.Freed))
+		exclusive += s.Exclusive
+		freed += s.Freed
+	}
+	fmt.Fprintf(w, "razem\t%s\t%s\t%s\t\n",
+		formatSize(exclusive), formatSize(u.Total-exclusive), formatSize(freed))
+	return w.Flush()
+}
+
+// formatSize zwraca rozmiar n (w bajtach) w postaci czytelnej dla
+// człowieka, np. "1.5G".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// pruneCommand usuwa najstarsze snapshoty, jeśli snapshoty zajmują
+// zbyt dużo miejsca: 'snapshot prune -dst=directory [-maxsize=size]
+// [-minfree=percent] [-keep=n]'.
+func pruneCommand(args []string) error {
+	fs := newFlagSet("prune")
+	dst := fs.String("dst", "", "")
+	var r snapshot.Retention
+	retentionFlags(fs, &r)
+	args = parseArgs(fs, args)
+	requireDst("prune", *dst)
+	requireArgs("prune", args, 0)
+	return snapshot.Prune(*dst, r)
+}
+
+// retentionFlags definiuje w fs opcje -maxsize, -minfree i -keep
+// ustawiające pola r.
+func retentionFlags(fs *flag.FlagSet, r *snapshot.Retention) {
+	fs.Var((*sizeValue)(&r.MaxSize), "maxsize", "")
+	fs.Var((*percentValue)(&r.MinFree), "minfree", "")
+	r.Keep = 1
+	fs.Var((*countValue)(&r.Keep), "keep", "")
+}
+
+// Typ sizeValue jest wartością opcji z rozmiarem w bajtach. Rozmiar
+// może mieć przyrostek K, M, G lub T (potęgi 1024), np. "500G".
+type sizeValue int64
+
+func (v *sizeValue) String() string {
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+func (v *sizeValue) Set(s string) error {
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]); i >= 0 {
+			for ; i >= 0; i-- {
+				mult *= 1024
+			}
+			s = s[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("ujemny rozmiar")
+	}
+	if n > math.MaxInt64/mult {
+		return fmt.Errorf("za duży rozmiar")
+	}
+	*v = sizeValue(n * mult)
+	return nil
+}
+
+// Typ percentValue jest wartością opcji z procentem od 0 do 100.
+type percentValue float64
+
+func (v *percentValue) String() string {
+	return strconv.FormatFloat(float64(*v), 'g', -1, 64)
+}
+
+func (v *percentValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	if !(f >= 0 && f <= 100) {
+		return fmt.Errorf("procent spoza przedziału 0-100")
+	}
+	*v = percentValue(f)
+	return nil
+}
+
+// Typ countValue jest wartością opcji z nieujemną liczbą całkowitą.
+type countValue int
+
+func (v *countValue) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+func (v *countValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("ujemna liczba")
+	}
+	*v = countValue(n)
+	return nil
+}
+
+// exportCommand zapisuje snapshot jako archiwum tar: 'snapshot export
+// -dst=directory [-at=timestamp] -o=file [-compress=type]
+// [-encrypt=type -recipient=key...]'. Jeśli file jest "-" to archiwum
+// jest zapisywane na stdout.
+func exportCommand(args []string) error {
+	fs := newFlagSet("export")
+	dst := fs.String("dst", "", "")
+	at := fs.String("at", "last", "")
+	out := fs.String("o", "", "")
+	compress := fs.String("compress", "", "")
+	var enc snapshot.Encryption
+	fs.StringVar(&enc.Method, "encrypt", "", "")
+	fs.Var((*listValue)(&enc.Recipients), "recipient", "")
+	args = parseArgs(fs, args)
+	requireDst("export", *dst)
+	requireArgs("export", args, 0)
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "snapshot: export: brakuje opcji -o")
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+	if *compress == "" {
+		*compress = snapshot.CompressionFromName(*out)
+	}
+	if enc.Method == "" {
+		enc.Method = snapshot.EncryptionFromName(*out)
+	}
+
+	name, err := resolveSnapshot(*dst, *at)
+	if err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		// stdout jest zajęty przez archiwum
+		snapshot.Output = os.Stderr
+		return snapshot.Export(*dst, name, os.Stdout, *compress, enc)
+	}
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	err = snapshot.Export(*dst, name, f, *compress, enc)
+	if err != nil {
+		f.Close()
+		os.Remove(*out)
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// importCommand odtwarza snapshot z archiwum utworzonego przez export:
+// 'snapshot import -dst=directory -i=file [-compress=type]
+// [-decrypt=type] [-identity=file]'. Jeśli file jest "-" to archiwum
+// jest czytane ze stdin.
+func importCommand(args []string) error {
+	fs := newFlagSet("import")
+	dst := fs.String("dst", "", "")
+	in := fs.String("i", "", "")
+	compress := fs.String("compress", "", "")
+	var enc snapshot.Encryption
+	fs.StringVar(&enc.Method, "decrypt", "", "")
+	fs.StringVar(&enc.Identity, "identity", "", "")
+	args = parseArgs(fs, args)
+	requireDst("import", *dst)
+	requireArgs("import", args, 0)
+	if *in == "" {
+		fmt.Fprintln(os.Stderr, "snapshot: import: brakuje opcji -i")
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+	if *compress == "" {
+		*compress = snapshot.CompressionFromName(*in)
+	}
+	if enc.Method == "" {
+		enc.Method = snapshot.EncryptionFromName(*in)
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	name, err := snapshot.Import(*dst, r, *compress, enc)
+	if err != nil {
+		return err
+	}
+	fmt.Println(name)
+	return nil
+}
+
+// historyCommand wyświetla wersje pliku we wszystkich snapshotach albo
+// wyświetla lub odtwarza wybraną wersję: 'snapshot history
+// -dst=directory [-at=timestamp -cat | -at=timestamp -restore=file]
+// pattern'.
+func historyCommand(args []string) error {
+	fs := newFlagSet("history")
+	dst := fs.String("dst", "", "")
+	at := fs.String("at", "", "")
+	cat := fs.Bool("cat", false, "")
+	restore := fs.String("restore", "", "")
+	args = parseArgs(fs, args)
+	requireDst("history", *dst)
+	requireArgs("history", args, 1)
+
+	versions, err := snapshot.History(*dst, args[0])
+	if err != nil {
+		return err
+	}
+
+	if *cat || *restore != "" {
+		if *at == "" {
+			fmt.Fprintln(os.Stderr, "snapshot: history: brakuje opcji -at")
+			fmt.Fprint(os.Stderr, usageText)
+			os.Exit(2)
+		}
+		name, err := resolveSnapshot(*dst, *at)
+		if err != nil {
+			return err
+		}
+		paths := make(map[string]bool)
+		var file string
+		for _, v := range versions {
+			paths[v.Path] = true
+			file = v.Path
+		}
+		if len(paths) != 1 {
+			return fmt.Errorf("wzorzec %q pasuje do %d plików zamiast do jednego", args[0], len(paths))
+		}
+		if *cat {
+			snapshot.Output = os.Stderr
+			return snapshot.CopyVersion(os.Stdout, *dst, name, file)
+		}
+		return snapshot.RestoreVersion(*dst, name, file, *restore)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	prev := ""
+	for _, v := range versions {
+		if v.Path != prev {
+			fmt.Fprintf(w, "%s\n", v.Path)
+			prev = v.Path
+		}
+		last := ""
+		if v.Count > 1 {
+			last = " .. " + v.Last
+		}
+		fmt.Fprintf(w, "\t%s%s\t(%d)\t%s\t%d\t%s\ti-węzeł %d\n",
+			v.First, last, v.Count, v.Mode, v.Size,
+			v.ModTime.Format("2006-01-02 15:04:05"), v.Inode)
+	}
+	return w.Flush()
+}
+
+// replicateCommand kopiuje brakujące snapshoty do drugiego katalogu z
+// backupami: 'snapshot replicate -from=directory -to=directory
+// [-logfile=filename] [-rsync=filename] [-rsyncopts=string]
+// [-maxsize=size] [-minfree=percent] [-keep=n]'. Opcje -maxsize,
+// -minfree i -keep dotyczą katalogu -to.
+func replicateCommand(args []string) error {
+	fs := newFlagSet("replicate")
+	from := fs.String("from", "", "")
+	to := fs.String("to", "", "")
+	logfile := fs.String("logfile", "", "")
+	fs.StringVar(&snapshot.RsyncCommand, "rsync", snapshot.RsyncCommand, "")
+	fs.StringVar(&snapshot.RsyncOptions, "rsyncopts", snapshot.RsyncOptions, "")
+	var r snapshot.Retention
+	retentionFlags(fs, &r)
+	args = parseArgs(fs, args)
+	requireArgs("replicate", args, 0)
+	if *from == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "snapshot: replicate: brakuje opcji -from lub -to")
+		fmt.Fprint(os.Stderr, usageText)
+		os.Exit(2)
+	}
+	if *logfile != "" {
+		file, err := openLogFile(*logfile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		snapshot.LogFile = file
+	}
+
+	err := snapshot.Replicate(*from, *to)
+	if err != nil {
+		return err
+	}
+	return snapshot.Prune(*to, r)
+}
+
+// openLogFile otwiera plik z logami do dopisywania.
+func openLogFile(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// resolveSnapshot zwraca nazwę snapshotu wskazywanego przez at. Jeśli
+// at jest równe "last" to zwraca nazwę snapshotu, na który wskazuje
+// symlink 'last'.
+func resolveSnapshot(dst, at string) (string, error) {
+	if at != "last" {
+		return at, nil
+	}
+	link, err := os.Readlink(filepath.Join(dst, "last"))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(link), nil
+}
+
+// Typ listValue jest wartością opcji, która może wystąpić wiele razy;
+// kolejne wartości są dodawane do listy.
+type listValue []string
+
+func (v *listValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *listValue) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
+
+// fsckCommand sprawdza spójność katalogu ze snapshotami: 'snapshot fsck
+// -dst=directory [-repair]'. Kończy się błędem jeśli zostały
+// nienaprawione problemy.
+func fsckCommand(args []string) error {
+	fs := newFlagSet("fsck")
+	dst := fs.String("dst", "", "")
+	repair := fs.Bool("repair", false, "")
+	args = parseArgs(fs, args)
+	requireDst("fsck", *dst)
+	requireArgs("fsck", args, 0)
+
+	problems, err := snapshot.Fsck(*dst, *repair)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, p := range problems {
+		if p.Repaired {
+			fmt.Printf("%s: %s (naprawione)\n", p.Kind, p.Message)
+			continue
+		}
+		fmt.Printf("%s: %s\n", p.Kind, p.Message)
+		n++
+	}
+	if n > 0 {
+		return fmt.Errorf("nienaprawione problemy: %d", n)
+	}
+	return nil
+}
+
+// checkCommand sprawdza aktualność snapshotów jak wtyczka Nagios/Icinga:
+// 'snapshot check -dst=directory... | -config=file [-warn=duration]
+// [-crit=duration]'. Wypisuje jeden wiersz ze stanem i kończy program
+// z kodem 0 (OK), 1 (WARNING), 2 (CRITICAL) lub 3 (UNKNOWN) - także
+// przy błędnych opcjach. Opcja -dst może wystąpić wiele razy, a z
+// opcją -config są sprawdzane katalogi dst wszystkich zadań z pliku
+// konfiguracyjnego programu backup.
+func checkCommand(args []string) error {
+	fs := newFlagSet("check")
+	// błąd opcji jest wypisywany w wierszu ze stanem UNKNOWN
+	fs.Init("check", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	var dsts listValue
+	fs.Var(&dsts, "dst", "")
+	config := fs.String("config", "", "")
+	warn := fs.Duration("warn", 26*time.Hour, "")
+	crit := fs.Duration("crit", 50*time.Hour, "")
+	var rest []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			checkExit(snapshot.CheckUnknown, err.Error())
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	switch {
+	case len(rest) > 0:
+		checkExit(snapshot.CheckUnknown, "błędna liczba argumentów")
+	case len(dsts) == 0 && *config == "":
+		checkExit(snapshot.CheckUnknown, "brakuje opcji -dst lub -config")
+	case *warn <= 0 || *crit < *warn:
+		checkExit(snapshot.CheckUnknown, "błędne wartości opcji -warn i -crit")
+	}
+
+	// ostrzeżenia są wypisywane na stderr, bo na stdout może być
+	// tylko wiersz ze stanem
+	snapshot.Output = os.Stderr
+
+	// format nazw snapshotów jest z opcji -naming, a dla zadań z
+	// pliku konfiguracyjnego - z opcji -naming zadania
+	type target struct {
+		label, dst string
+		layout     string
+		utc        bool
+	}
+	var targets []target
+	for _, dst := range dsts {
+		targets = append(targets, target{dst, dst, snapshot.NameLayout, snapshot.NameUTC})
+	}
+	if *config != "" {
+		c, err := job.ReadConfig(*config)
+		if err != nil {
+			checkExit(snapshot.CheckUnknown, err.Error())
+		}
+		if len(c.List) == 0 {
+			checkExit(snapshot.CheckUnknown, "brak zadań w pliku konfiguracyjnym")
+		}
+		for _, j := range c.List {
+			layout, utc, err := job.NamingLayout(c, j)
+			if err != nil {
+				checkExit(snapshot.CheckUnknown, fmt.Sprintf("zadanie %q: %s", j.Name, err))
+			}
+			targets = append(targets, target{j.Name, j.Dst, layout, utc})
+		}
+	}
+
+	state := snapshot.CheckOK
+	var msgs, perf []string
+	for _, t := range targets {
+		h := snapshot.CheckLayout(t.dst, t.layout, t.utc, *warn, *crit)
+		state = snapshot.WorstState(state, h.State)
+		msg := t.label + ": " + h.Message
+		if len(targets) > 1 && h.State != snapshot.CheckOK {
+			msg += " (" + snapshot.StateName(h.State) + ")"
+		}
+		msgs = append(msgs, msg)
+		if h.Meta != nil {
+			perf = append(perf, fmt.Sprintf("'%s'=%ds;%d;%d;0", t.label,
+				int64(h.Age.Seconds()), int64(warn.Seconds()), int64(crit.Seconds())))
+		}
+	}
+	line := strings.Join(msgs, "; ")
+	if len(perf) > 0 {
+		line += " | " + strings.Join(perf, " ")
+	}
+	checkExit(state, line)
+	return nil
+}
+
+// checkExit wypisuje wiersz ze stanem state i opisem msg w postaci
+// wtyczki Nagios i kończy program z kodem state.
+func checkExit(state int, msg string) {
+	fmt.Printf("SNAPSHOT %s - %s\n", snapshot.StateName(state), msg)
+	os.Exit(state)
+}
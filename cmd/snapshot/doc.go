@@ -9,11 +9,14 @@ snapshocie.
 
 Sposób użycia:
 	snapshot [opcje] -src=filesystem -dst=directory
+	snapshot polecenie [opcje] [argumenty]
 Opcje:
 	-src filesystem
-		backupowany filesystem
+		backupowany filesystem (lokalny, "host:/path" lub
+		"rsync://host/module/path")
 	-dst directory
-		docelowy katalog z backupami
+		docelowy katalog z backupami (lokalny, "host:/path" lub
+		"rsync://host/module/path")
 	-exclude string
 		lista wzorców ignorowanych plików "pattern,pattern,..."
 		(domyślnie: "")
@@ -23,8 +26,88 @@ Opcje:
 		nazwa polecenia rsync (domyślnie: "rsync")
 	-rsyncopts string
 		opcje polecenia rsync (domyślnie: "-avxH8")
+	-ssh string
+		polecenie ssh z opcjami dla zdalnych katalogów
+		(domyślnie: "ssh")
+	-identity filename
+		plik z kluczem prywatnym ssh (domyślnie: "")
+	-password-file filename
+		plik z hasłem do modułu serwera rsync (domyślnie: "")
+	-progress
+		raportowanie postępu snapshotu (procent wykonania i
+		przewidywany czas do końca)
+	-status filename
+		plik, do którego jest zapisywany stan snapshotu (włącza
+		-progress) (domyślnie: "")
+	-linkdest n
+		liczba najnowszych snapshotów (od 1 do 20) używanych
+		jako --link-dest (domyślnie: 1, czyli tylko 'last')
+	-linkdest-pinned
+		używanie także przypiętych snapshotów jako --link-dest
+	-naming format
+		format nazw snapshotów: local, utc, offset lub format
+		czasu Go, np. "2006-01-02T15:04:05.000" (domyślnie:
+		"local"); opcja jest dostępna także dla poleceń
+	-maxsize size
+		maksymalne miejsce zajęte przez snapshoty, np. "500G"
+		(domyślnie: 0, czyli bez ograniczenia)
+	-minfree percent
+		minimalny procent wolnego miejsca na filesystemie z
+		backupami (domyślnie: 0, czyli bez ograniczenia)
+	-keep n
+		liczba najnowszych snapshotów, które nie są usuwane
+		przy przekroczeniu -maxsize lub -minfree (domyślnie: 1)
 	-h	sposób użycia
 	-help	dokumentacja
+Polecenia:
+	pin -dst=directory [-reason=string] [-readonly] [timestamp]
+		przypina snapshot timestamp; bez argumentu timestamp
+		wyświetla listę przypiętych snapshotów
+	unpin -dst=directory timestamp
+		odpina snapshot timestamp
+	delete -dst=directory timestamp
+		usuwa snapshot timestamp
+	usage -dst=directory
+		wyświetla zajętość miejsca przez snapshoty
+	prune -dst=directory [-maxsize=size] [-minfree=percent] [-keep=n]
+		usuwa najstarsze snapshoty, jeśli zajmują za dużo miejsca
+	export -dst=directory [-at=timestamp] -o=file [-compress=type]
+	    [-encrypt=type -recipient=key...]
+		zapisuje snapshot timestamp (domyślnie: "last") do
+		pliku file jako archiwum tar; jeśli file jest "-" to
+		archiwum jest zapisywane na stdout; kompresja: none,
+		gzip lub zstd; szyfrowanie: age lub gpg (domyślnie:
+		według rozszerzenia file, np. ".tar.zst.age"); opcja
+		-recipient może wystąpić wiele razy
+	import -dst=directory -i=file [-compress=type] [-decrypt=type]
+	    [-identity=file]
+		odtwarza snapshot z archiwum file utworzonego przez
+		export; jeśli file jest "-" to archiwum jest czytane
+		ze stdin; -identity jest plikiem z kluczem prywatnym
+		age
+	history -dst=directory [-at=timestamp -cat | -restore=file]
+	    pattern
+		wyświetla wersje plików pasujących do pattern we
+		wszystkich snapshotach; z opcją -at wyświetla (-cat)
+		lub odtwarza do pliku file (-restore) wersję pliku ze
+		snapshotu timestamp
+	replicate -from=directory -to=directory [-logfile=filename]
+	    [-rsync=filename] [-rsyncopts=string]
+	    [-maxsize=size] [-minfree=percent] [-keep=n]
+		kopiuje do katalogu -to snapshoty z katalogu -from,
+		których brakuje w -to; opcje -maxsize, -minfree i
+		-keep dotyczą katalogu -to
+	fsck -dst=directory [-repair]
+		sprawdza spójność katalogu ze snapshotami; z opcją
+		-repair naprawia problemy, które można naprawić
+		bezpiecznie
+	check -dst=directory... | -config=file [-warn=duration]
+	    [-crit=duration]
+		sprawdza aktualność najnowszego kompletnego snapshotu
+		jak wtyczka Nagios/Icinga (domyślnie: -warn=26h
+		-crit=50h); opcja -dst może wystąpić wiele razy, a z
+		opcją -config są sprawdzane katalogi dst wszystkich
+		zadań z pliku konfiguracyjnego programu backup
 
 Do kopiowania jest używane polecenie rsync(1) z następującymi opcjami:
 
@@ -35,5 +118,245 @@ Do kopiowania jest używane polecenie rsync(1) z następującymi opcjami:
 	-8			8-bit output
 	--link-dest=DIR		hardlink to files in DIR when unchanged
 	--exclude=PATTERN	exclude files matching PATTERN
+
+Katalogi -src i -dst mogą być zdalne, w postaci "host:/path". Zdalny
+-src pozwala robić backup innych komputerów, a zdalny -dst - zapisywać
+snapshoty na serwerze z backupami. Do połączenia jest używane
+polecenie z opcji -ssh (np. "ssh -p 2222") z kluczem z opcji
+-identity. Na zdalnym hoście katalog roboczy, zmiana jego nazwy na
+timestamp i symlink 'last' są obsługiwane przez polecenia powłoki
+(mkdir, mv, ln, rm) wykonywane przez ssh. Dla zdalnego -dst nie są
+obsługiwane opcje -maxsize i -minfree.
+
+Katalogi -src i -dst mogą być też w module serwera rsync (rsync
+--daemon), w postaci "rsync://user@host/module/path". Hasło do modułu
+jest czytane z pliku z opcji -password-file lub ze zmiennej
+środowiskowej RSYNC_PASSWORD. Protokół rsync nie pozwala zmienić
+nazwy katalogu, więc w module -dst snapshot jest kopiowany od razu do
+katalogu z timestampem (z opcją --link-dest wskazującą na poprzedni
+snapshot). Przed uruchomieniem rsync w katalogu .meta jest zapisywany
+plik z metadanymi ze statusem "running", a po zakończeniu rsync jest
+on zastępowany plikiem ze statusem "complete" - znacznikiem
+zakończenia; przerwany snapshot nie jest uznawany za kompletny.
+Moduł musi mieć ustawione "read only = false" i "munge symlinks =
+false", żeby symlink 'last' działał.
+
+Opcja -progress uruchamia rsync z opcjami --info=progress2 i
+--no-inc-recursive. Jeśli stderr jest terminalem, postęp (procent
+wykonania, liczba przesłanych bajtów, prędkość, przewidywany czas do
+końca i liczba sprawdzonych plików) jest pokazywany w jednej linii
+na stderr, a nazwy kopiowanych plików są zapisywane tylko do pliku
+logu. Co 10 sekund postęp jest logowany i zapisywany do pliku z opcji
+-status. Plik statusu zawiera linie "klucz: wartość" (state, src, dst,
+pid, begin, update, percent, bytes, rate, eta, files, total i error)
+i jest zamieniany atomowo, więc inne programy mogą go czytać w
+dowolnym momencie. Po zakończeniu snapshotu state ma wartość "done"
+lub "failed".
+
+Komunikaty wypisywane przez rsync na stderr są logowane (także do
+pliku z opcji -logfile) z poziomem "warning:" i z rodzajem komunikatu:
+permission (brak uprawnień), vanished (plik zniknął w czasie
+kopiowania), io (błąd wejścia/wyjścia), nospace (brak miejsca na
+dysku), connection (błąd połączenia), partial (podsumowanie rsync: nie
+wszystkie pliki zostały skopiowane) lub other. Po zakończeniu rsync
+jest logowana liczba komunikatów każdego rodzaju.
+
+Niezmienione pliki są hard linkami do plików z poprzedniego snapshotu
+wskazywanego przez 'last' (opcja --link-dest rsync). Opcja -linkdest
+pozwala podać rsync także kolejne najnowsze snapshoty, a opcja
+-linkdest-pinned - przypięte snapshoty (razem najwyżej 20 katalogów).
+Wtedy plik, którego nie ma w 'last', ale jest w jednym z tych
+snapshotów (np. plik odtworzony ze starszego snapshotu albo pominięty
+w nieudanym snapshocie), też jest hard linkiem, a nie nową kopią.
+
+Po zakończeniu rsync dane snapshotu są zapisywane na dysk (fsync
+wszystkich plików i katalogów), a potem jest zapisywany plik
+.meta/timestamp z metadanymi (linie "klucz: wartość": status, src,
+begin, end i errors), który jest znacznikiem zakończenia snapshotu.
+Metadane są w katalogu .meta w -dst, a nie w katalogu snapshotu, więc
+nie są częścią backupowanych danych (np. dla poleceń history i
+export). Dopiero potem nazwa katalogu roboczego jest zmieniana na
+timestamp. Symlink 'last' jest zmieniany atomowo: nowy symlink jest
+tworzony jako .last.tmp i jego nazwa jest zmieniana na 'last', więc
+po przerwaniu programu lub awarii systemu 'last' wskazuje na
+poprzedni albo na nowy snapshot. Dla zdalnego -dst przez ssh dane są
+zapisywane na dysk poleceniem sync(1).
+
+Snapshot jest kompletny, gdy ma znacznik zakończenia ze statusem
+"complete". Snapshot bez znacznika jest uznawany za utworzony przez
+starszą wersję programu (i kompletny), jeśli wskazuje na niego symlink
+'last' albo jest starszy od wszystkich snapshotów ze znacznikiem.
+Pozostałe snapshoty bez znacznika (np. przerwane przed jego
+zapisaniem) nie są uznawane za kompletne, a polecenie snapshot
+wypisuje dla nich ostrzeżenie. Jeśli symlink 'last' nie istnieje (np.
+został usunięty), wskazuje na usunięty snapshot albo na niekompletny
+snapshot, to jako --link-dest jest używany najnowszy kompletny
+snapshot, a symlink 'last' jest naprawiany. Jeśli w -dst nie ma
+kompletnych snapshotów, to jako --link-dest jest używany najnowszy
+snapshot bez znacznika (rsync tworzy hard linki tylko do plików takich
+samych jak w -src, więc jest to bezpieczne), a pełna kopia jest
+wykonywana tylko wtedy, gdy w -dst nie ma żadnego snapshotu.
+
+Nazwa snapshotu jest czasem jego zakończenia (w module serwera rsync
+- czasem rozpoczęcia, bo rsync kopiuje od razu do katalogu z
+timestampem). Opcja -naming określa format nazwy:
+
+	local	czas lokalny, np. "2015-02-10T18:07:39" (domyślny,
+		format starszych wersji programu)
+	utc	czas UTC, np. "2015-02-10T17:07:39Z"
+	offset	czas lokalny z przesunięciem strefy czasowej, np.
+		"2015-02-10T18:07:39+01:00"
+
+Format może być też podany jako format czasu Go (jak w time.Format),
+np. "2006-01-02T15:04:05.000" dla nazw z milisekundami; prefiks
+"utc:" oznacza czas UTC. Format musi zawierać pełną datę i czas do
+sekundy. Jeśli snapshot o danej nazwie już istnieje (np. dwa
+snapshoty w tej samej sekundzie albo powtórzona godzina przy zmianie
+czasu z letniego na zimowy), do nazwy jest dodawany numer kolejny, np.
+"2015-02-10T18:07:39_1". Nazwy w formatach local, utc i offset (także
+z częścią ułamkową sekund) są rozpoznawane zawsze, więc można zmienić
+format bez zmiany nazw starszych snapshotów; nazwy we własnym formacie
+są rozpoznawane tylko z opcją -naming. Snapshoty są porządkowane
+według czasu z nazwy, a nie alfabetycznie. Zmiana formatu z local na
+"utc:2006-01-02T15:04:05" nie jest zalecana, bo nazwy starszych
+snapshotów byłyby odczytywane jako czas UTC.
+
+Polecenie fsck sprawdza katalog ze snapshotami i wypisuje znalezione
+problemy, każdy w postaci "rodzaj: opis". Rodzaje problemów:
+
+	last		brak symlinku 'last', wskazuje on na inny
+			katalog niż najnowszy kompletny snapshot albo
+			pozostał tymczasowy symlink .last.tmp
+	workdir		pozostawiony katalog roboczy 'snapshot' lub
+			'import' (niedokończony albo trwający snapshot)
+	name		plik, którego nazwa nie jest timestampem
+	duplicate	snapshoty z tym samym czasem
+	future		snapshot z czasem w przyszłości
+	nometa		snapshot bez znacznika zakończenia (pliku
+			.meta/timestamp)
+	incomplete	snapshot niekompletny według metadanych
+	hardlink	pliki, które mają takie same atrybuty jak w
+			poprzednim snapshocie, ale nie są hard linkami
+			(np. po skopiowaniu snapshotów bez opcji -H)
+	pin		przypięcie nieistniejącego snapshotu
+	meta		metadane nieistniejącego snapshotu (np. po
+			przerwaniu programu przed zmianą nazwy katalogu
+			roboczego), plik tymczasowy pozostały po
+			przerwanym zapisie metadanych albo metadane ze
+			statusem "running" snapshotu w module serwera
+			rsync, którego katalog nie istnieje
+
+Z opcją -repair naprawiane są problemy last (symlink jest ustawiany
+na najnowszy kompletny snapshot, a .last.tmp jest usuwany), nometa
+dla snapshotów utworzonych przez starszą wersję programu (zapisywane
+są metadane ze statusem "complete"; inne snapshoty bez znacznika mogą
+być niedokończone i są tylko raportowane), pin i meta (usuwane jest
+przypięcie, metadane lub plik tymczasowy; metadane ze statusem
+"running" nie są usuwane, bo snapshot może jeszcze trwać) i hardlink
+(pliki o takiej samej zawartości są zamieniane na hard linki; nie są
+zmieniane pliki w przypiętych snapshotach ani pliki, które mają inne
+hard linki, np. wewnątrz snapshotu). Pozostałe problemy wymagają
+decyzji użytkownika, np. katalog roboczy może należeć do trwającego
+snapshotu. Polecenie kończy się kodem 1, jeśli zostały
+nienaprawione problemy.
+
+Polecenie check służy do monitorowania backupów przez Nagios, Icinga
+lub podobne systemy. Sprawdza wiek najnowszego kompletnego snapshotu
+(czas z nazwy snapshotu) i jego metadane, wypisuje jeden wiersz ze
+stanem i kończy się kodem wyjścia wtyczek Nagios:
+
+	0	OK - najnowszy snapshot nie jest starszy niż -warn
+	1	WARNING - snapshot starszy niż -warn albo z czasem w
+		przyszłości
+	2	CRITICAL - snapshot starszy niż -crit albo brak
+		kompletnych snapshotów
+	3	UNKNOWN - nie można odczytać katalogu lub błędne opcje
+
+Przy kilku katalogach wynikiem jest najgorszy stan (CRITICAL, potem
+UNKNOWN, WARNING i OK), a wiersz zawiera opis każdego katalogu. Jeśli
+snapshot ma w metadanych błędy rsync, są one podawane w opisie. Po
+znaku '|' są dane wydajnościowe: wiek snapshotu w sekundach z progami
+-warn i -crit. Przykład:
+
+	$ snapshot check -config=/etc/backup.conf
+	SNAPSHOT WARNING - root: najnowszy snapshot 2015-02-10T03:30:00
+	(1d4h temu) (WARNING); home: najnowszy snapshot
+	2015-02-11T03:30:00 (4h30m temu) | 'root'=102600s;93600;180000;0
+	'home'=16200s;93600;180000;0
+
+(wiersz jest tu podzielony dla czytelności). Komunikaty programu, np.
+ostrzeżenia o błędnych metadanych, są wypisywane na stderr.
+
+Jeśli po wykonaniu snapshotu miejsce zajęte przez snapshoty jest
+większe niż -maxsize lub wolnego miejsca na filesystemie jest mniej niż
+-minfree procent, to najstarsze snapshoty są usuwane dopóki limit nie
+zostanie spełniony. Liczba usuwanych snapshotów jest wyznaczana na
+podstawie miejsca, które faktycznie zwolni ich usunięcie (jak w
+poleceniu usage). Nigdy nie są usuwane snapshoty przypięte, snapshot
+wskazywany przez 'last' ani -keep najnowszych snapshotów. To samo
+robi polecenie prune.
+
+Przypięty snapshot (np. zrobiony przed aktualizacją systemu) nie może
+być usunięty poleceniem delete ani prune. Informacje o przypiętych
+snapshotach są przechowywane w katalogu 'pins' w katalogu z backupami.
+Z opcją -readonly wszystkim katalogom przypinanego snapshotu jest
+odbierane prawo zapisu, więc przypadkowe 'rm -rf' się nie powiedzie;
+polecenie unpin przywraca oryginalne prawa dostępu. Nie można też
+usunąć snapshotu, na który wskazuje symlink 'last'.
+
+Polecenie usage wyświetla miejsce zajęte przez snapshoty z
+uwzględnieniem hard linków tworzonych przez --link-dest (du(1) podaje
+tu mylące wyniki). Dla każdego snapshotu podaje miejsce zajęte przez
+pliki używane tylko przez ten snapshot (wyłączne), przez pliki
+współdzielone z innymi snapshotami (współdzielone) oraz miejsce, które
+faktycznie zwolni usunięcie snapshotu (zwolni). W wierszu 'razem'
+kolumna współdzielone zawiera miejsce zajęte przez wszystkie pliki
+współdzielone.
+
+Polecenie export zapisuje snapshot jako archiwum tar w formacie
+POSIX/PAX, np. w celu skopiowania go poza siedzibę. Zachowywane są
+prawa dostępu, właściciel, rozszerzone atrybuty, symlinki i hard linki
+wewnątrz snapshotu, a metadane snapshotu są zapisywane w rekordzie PAX
+katalogu snapshotu. Archiwum może być skompresowane przez gzip lub
+zstd(1). Archiwum jest zapisywane strumieniowo, bez plików
+tymczasowych, więc z '-o -' może być przekazane do innego programu.
+
+Archiwum przeznaczone do przechowywania poza własnymi dyskami (np. w
+chmurze) może być zaszyfrowane kluczem publicznym w formacie age lub
+OpenPGP przy użyciu poleceń age(1) lub gpg(1), więc na komputerze z
+backupami wystarczy klucz publiczny. Polecenie import rozszyfrowuje
+archiwum kluczem prywatnym i odtwarza z niego snapshot w katalogu z
+backupami. Snapshot pojawia się dopiero po przeczytaniu całego
+archiwum i sprawdzeniu integralności danych; w razie błędu
+rozpakowane pliki są usuwane. Import kończy się błędem, jeśli w
+katalogu z backupami jest już snapshot z tym samym czasem (także w
+innym formacie nazwy). Niekompletny snapshot z archiwum jest
+importowany z ostrzeżeniem i nie jest uznawany za kompletny.
+
+Polecenie history pokazuje, w których snapshotach istnieje plik i kiedy
+się zmieniał. Argument pattern jest nazwą pliku względem katalogu
+snapshotu (np. "home/adbr/notes/todo.org") i może zawierać metaznaki
+jak w path.Match oraz element '**' pasujący do dowolnej liczby
+katalogów; np. wzorzec złożony z elementów '**' i '*.ods' pasuje do
+plików .ods w dowolnym katalogu. Dla każdej wersji pliku są
+wyświetlane snapshoty, w których występuje, prawa dostępu, rozmiar,
+czas modyfikacji i numer i-węzła. Kolejne snapshoty z tym samym
+i-węzłem (plik nie zmienił się i jest hard linkiem) są pokazywane jako
+jedna wersja.
+
+Polecenie replicate kopiuje łańcuch snapshotów do drugiego katalogu z
+backupami (np. na dysku przechowywanym poza domem). Kopiowane są tylko
+snapshoty, których brakuje w katalogu docelowym, w kolejności
+chronologicznej. Każdy snapshot jest kopiowany poleceniem rsync z
+opcją --link-dest wskazującą na poprzedni snapshot w katalogu
+docelowym, więc pliki są tam współdzielone przez hard linki tak samo
+jak w katalogu źródłowym. Snapshoty bez znacznika zakończenia są
+pomijane. Skopiowany snapshot jest zapisywany na dysk tak jak nowy
+snapshot. Kopiowane są też informacje o przypiętych snapshotach
+(przypiętym z -readonly snapshotom jest też w katalogu docelowym
+odbierane prawo zapisu katalogów), a symlink 'last' jest ustawiany
+na ten sam snapshot co w katalogu źródłowym. W katalogu docelowym
+mogą obowiązywać inne limity miejsca (-maxsize, -minfree, -keep) niż
+w źródłowym.
 */
 package main
@@ -0,0 +1,204 @@
+// 2026-10-18 adbr
+
+// Pakiet job wykonuje zadania backupu opisane w pliku konfiguracyjnym.
+// Zadanie jest wywołaniem programu snapshot dla jednego backupowanego
+// katalogu. Niezależne zadania są wykonywane równolegle z
+// ograniczeniem liczby wszystkich zadań i liczby zadań zapisujących na
+// to samo urządzenie.
+package job
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Typ Config zawiera konfigurację zadań backupu.
+type Config struct {
+	Jobs       int           // maksymalna liczba równolegle wykonywanych zadań
+	DeviceJobs int           // maksymalna liczba zadań zapisujących na to samo urządzenie
+	Snapshot   string        // polecenie snapshot
+	Options    []string      // opcje polecenia snapshot dla wszystkich zadań
+	Retry      time.Duration // czas do ponowienia nieudanego zadania (Daemon)
+	LockDir    string        // katalog plików blokad zadań
+	List       []*Job        // zadania w kolejności z pliku
+}
+
+// Typ Job opisuje zadanie backupu.
+type Job struct {
+	Name     string   // nazwa zadania
+	Src      string   // backupowany katalog (opcja -src)
+	Dst      string   // katalog ze snapshotami (opcja -dst)
+	Exclude  string   // wzorce ignorowanych plików (opcja -exclude)
+	Device   string   // urządzenie docelowe; domyślnie wyznaczane z Dst
+	Options  []string // dodatkowe opcje polecenia snapshot
+	Schedule Schedule // harmonogram (Daemon); nil - zadanie tylko na żądanie
+}
+
+// ReadConfig wczytuje konfigurację z pliku file. Plik składa się z
+// wierszy "klucz = wartość"; wiersze puste i zaczynające się od '#' są
+// pomijane. Wiersz "[nazwa]" rozpoczyna opis zadania o tej nazwie.
+// Klucze przed pierwszym zadaniem są ustawieniami globalnymi:
+//
+//	jobs		maksymalna liczba równoległych zadań (domyślnie: 1)
+//	device-jobs	maksymalna liczba równoległych zadań na jedno
+//			urządzenie docelowe (domyślnie: 1)
+//	snapshot	polecenie snapshot (domyślnie: "snapshot")
+//	options		opcje polecenia snapshot dla wszystkich zadań
+//	retry		czas do ponowienia nieudanego zadania przez
+//			Daemon (domyślnie: 1h)
+//	lockdir		katalog plików blokad, które zapobiegają
+//			równoległemu wykonaniu tego samego zadania
+//			(domyślnie: DefaultLockDir(); pusty, jeśli nie
+//			ma katalogu domyślnego - wtedy zadania kończą się
+//			błędem ErrNoLockDir)
+//
+// Klucze zadania: src, dst (wymagane), exclude, device, options i
+// schedule (harmonogram w postaci opisanej w ParseSchedule).
+func ReadConfig(file string) (*Config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseConfig(f, file)
+}
+
+// parseConfig parsuje konfigurację z r. Argument file jest nazwą
+// pliku używaną w komunikatach błędów.
+func parseConfig(r io.Reader, file string) (*Config, error) {
+	c := &Config{
+		Jobs:       1,
+		DeviceJobs: 1,
+		Snapshot:   "snapshot",
+		Retry:      time.Hour,
+		LockDir:    DefaultLockDir(),
+	}
+	var job *Job
+	n := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", file, n, fmt.Sprintf(format, args...))
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, errorf("błędny wiersz %q", line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" || strings.ContainsAny(name, " \t") {
+				return nil, errorf("błędna nazwa zadania %q", name)
+			}
+			if c.Job(name) != nil {
+				return nil, errorf("powtórzona nazwa zadania %q", name)
+			}
+			job = &Job{Name: name}
+			c.List = append(c.List, job)
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, errorf("błędny wiersz %q", line)
+		}
+		key := strings.TrimSpace(line[:i])
+		val := strings.TrimSpace(line[i+1:])
+
+		var err error
+		if job == nil {
+			err = c.set(key, val)
+		} else {
+			err = job.set(key, val)
+		}
+		if err != nil {
+			return nil, errorf("%s", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, job := range c.List {
+		if job.Src == "" || job.Dst == "" {
+			return nil, fmt.Errorf("%s: zadanie %q: brakuje src lub dst", file, job.Name)
+		}
+	}
+	return c, nil
+}
+
+// set ustawia globalne ustawienie key na wartość val.
+func (c *Config) set(key, val string) error {
+	switch key {
+	case "jobs", "device-jobs":
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%s: błędna liczba zadań %q", key, val)
+		}
+		if key == "jobs" {
+			c.Jobs = n
+		} else {
+			c.DeviceJobs = n
+		}
+	case "snapshot":
+		c.Snapshot = val
+	case "options":
+		c.Options = strings.Fields(val)
+	case "retry":
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%s: błędny czas %q", key, val)
+		}
+		c.Retry = d
+	case "lockdir":
+		c.LockDir = val
+	default:
+		return fmt.Errorf("nieznany klucz %q", key)
+	}
+	return nil
+}
+
+// set ustawia pole zadania key na wartość val.
+func (job *Job) set(key, val string) error {
+	switch key {
+	case "src":
+		job.Src = val
+	case "dst":
+		job.Dst = val
+	case "exclude":
+		job.Exclude = val
+	case "device":
+		job.Device = val
+	case "options":
+		job.Options = strings.Fields(val)
+	case "schedule":
+		sched, err := ParseSchedule(val)
+		if err != nil {
+			return fmt.Errorf("zadanie %q: %s", job.Name, err)
+		}
+		job.Schedule = sched
+	default:
+		return fmt.Errorf("zadanie %q: nieznany klucz %q", job.Name, key)
+	}
+	return nil
+}
+
+// Job zwraca zadanie o nazwie name lub nil, jeśli takiego zadania nie
+// ma.
+func (c *Config) Job(name string) *Job {
+	for _, job := range c.List {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// 2026-10-18 adbr
+
+package job
+
+import (
+	"time"
+
+	"github.com/adbr/backup/internal/snapshot"
+)
+
+// Zmienna CheckInterval określa, jak często Daemon sprawdza, czy
+// zadania powinny być wykonane.
+var CheckInterval = time.Minute
+
+// Typ jobState zawiera stan zadania z harmonogramem w Daemon.
+type jobState struct {
+	job    *Job
+	device string
+	last   time.Time // czas ostatniego udanego wykonania
+	retry  time.Time // nie ponawiać przed tym czasem po błędzie
+}
+
+// Daemon wykonuje zadania jobs z konfiguracji c według ich
+// harmonogramów (Schedule), aż do zamknięcia kanału stop. Zadania bez
+// harmonogramu są pomijane. Czas ostatniego udanego
+// wykonania zadania jest czasem najnowszego kompletnego snapshotu w
+// katalogu Dst, więc uwzględniane są też snapshoty utworzone poza
+// Daemon. Zadanie jest wykonywane, gdy minął najbliższy po tym czasie
+// termin z harmonogramu - tak jak w anacron(8) zadania pominięte, bo
+// komputer był wyłączony lub uśpiony, są wykonywane zaraz po
+// uruchomieniu programu lub wybudzeniu komputera. Nieudane zadanie
+// jest ponawiane po c.Retry. Zadanie nie jest uruchamiane, jeśli
+// jeszcze się wykonuje; jeśli wykonuje je inny proces, to jest
+// sprawdzane ponownie w następnym terminie z harmonogramu. Po
+// zamknięciu stop Daemon czeka na zakończenie wykonywanych zadań.
+// Limity równoległych zadań są takie same jak w Run.
+func Daemon(c *Config, jobs []*Job, stop <-chan struct{}) {
+	r := newRunner(c)
+	var states []*jobState
+	for _, job := range jobs {
+		if job.Schedule == nil {
+			continue
+		}
+		st := &jobState{job: job, device: Device(job)}
+		st.update(lastSuccess(c, job))
+		states = append(states, st)
+		logf("zadanie %q (%s): ostatni snapshot: %s, następne wykonanie: %s",
+			job.Name, job.Schedule, formatTime(st.last), formatNext(st.next()))
+	}
+
+	ticker := time.NewTicker(CheckInterval)
+	defer ticker.Stop()
+	for {
+		// czas bez odczytu zegara monotonicznego, który nie
+		// biegnie podczas uśpienia komputera
+		now := time.Now().Round(0)
+		for _, st := range states {
+			if !st.due(now) || !r.canStart(st.job, st.device) {
+				continue
+			}
+			// snapshot mógł zostać utworzony poza Daemon
+			st.update(lastSuccess(c, st.job))
+			if st.due(now) {
+				r.start(st.job, st.device)
+			}
+		}
+
+		select {
+		case <-stop:
+			if r.running > 0 {
+				logf("zakończenie po wykonaniu bieżących zadań")
+			}
+			for r.running > 0 {
+				r.finish(<-r.done)
+			}
+			return
+		case res := <-r.done:
+			r.finish(res)
+			st := stateOf(states, res.Job)
+			switch {
+			case res.Err == nil:
+				st.update(res.Begin.Round(0))
+				st.update(lastSuccess(c, res.Job))
+			default:
+				st.failed(res.Err, res.End.Round(0), c.Retry)
+			}
+			logf("zadanie %q: następne wykonanie: %s", st.job.Name, formatNext(st.next()))
+		case <-ticker.C:
+		}
+	}
+}
+
+// update ustawia czas ostatniego udanego wykonania zadania na t, jeśli
+// t jest późniejszy.
+func (st *jobState) update(t time.Time) {
+	if t.After(st.last) {
+		st.last = t
+	}
+}
+
+// failed ustawia czas ponowienia zadania zakończonego w chwili end
+// błędem err: po czasie retry, a jeśli zadanie jest wykonywane przez
+// inny proces (ErrLocked) - w następnym terminie z harmonogramu, bo
+// tamten proces utworzy snapshot, a sprawdzanie blokady co
+// CheckInterval tylko zapełniałoby log błędami.
+func (st *jobState) failed(err error, end time.Time, retry time.Duration) {
+	if err == ErrLocked {
+		st.retry = st.job.Schedule.Next(end)
+		return
+	}
+	st.retry = end.Add(retry)
+}
+
+// next zwraca czas następnego wykonania zadania; zerowy czas oznacza,
+// że zadanie powinno być wykonane od razu.
+func (st *jobState) next() time.Time {
+	if st.last.IsZero() {
+		return st.retry
+	}
+	next := st.job.Schedule.Next(st.last)
+	if next.Before(st.retry) {
+		return st.retry
+	}
+	return next
+}
+
+// due sprawdza czy zadanie powinno być wykonane w chwili now.
+func (st *jobState) due(now time.Time) bool {
+	next := st.next()
+	return next.IsZero() || !next.After(now)
+}
+
+// stateOf zwraca stan zadania job.
+func stateOf(states []*jobState, job *Job) *jobState {
+	for _, st := range states {
+		if st.job == job {
+			return st
+		}
+	}
+	panic("job: brak stanu zadania " + job.Name)
+}
+
+// lastSuccess zwraca czas najnowszego kompletnego snapshotu zadania
+// job z konfiguracji c albo zerowy czas, jeśli nie ma takiego snapshotu
+// lub nie można go odczytać. Snapshoty są odczytywane, a czas jest
+// odczytywany z nazwy snapshotu, w formacie z opcji -naming zadania
+// (NamingLayout).
+func lastSuccess(c *Config, job *Job) time.Time {
+	layout, utc, err := NamingLayout(c, job)
+	if err != nil {
+		logf("zadanie %q: %s", job.Name, err)
+		return time.Time{}
+	}
+	m, err := snapshot.NewestCompleteLayout(job.Dst, layout, utc)
+	if err != nil {
+		logf("zadanie %q: %s", job.Name, err)
+		return time.Time{}
+	}
+	if m == nil {
+		return time.Time{}
+	}
+	if id, err := snapshot.ParseIDLayout(m.Snapshot, layout, utc); err == nil {
+		return id.Time
+	}
+	return m.Begin
+}
+
+// formatTime zwraca czas t w postaci do komunikatów.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "brak"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// formatNext zwraca czas następnego wykonania zadania next w postaci do
+// komunikatów.
+func formatNext(next time.Time) string {
+	if next.IsZero() || !next.After(time.Now()) {
+		return "zaraz"
+	}
+	return formatTime(next)
+}
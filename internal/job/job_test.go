@@ -0,0 +1,280 @@
+// 2026-10-18 adbr
+
+package job
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	conf := `# konfiguracja
+jobs = 3
+options = -logfile=/var/log/backup.log -linkdest=2
+
+[root]
+src = /
+dst = /backup/root
+
+[home]
+src = /home
+dst = host:/backup/home
+exclude = adbr/tmp/*,.cache/*
+device = usb
+schedule = 30 3 * * *
+`
+	c, err := parseConfig(strings.NewReader(conf), "backup.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Jobs != 3 || c.DeviceJobs != 1 || c.Snapshot != "snapshot" || len(c.List) != 2 {
+		t.Fatalf("parseConfig: %+v", c)
+	}
+	home := c.Job("home")
+	if home.Schedule == nil || home.Schedule.String() != "30 3 * * *" {
+		t.Errorf("zadanie home: harmonogram %v", home.Schedule)
+	}
+	home.Schedule = nil
+	want := &Job{Name: "home", Src: "/home", Dst: "host:/backup/home", Exclude: "adbr/tmp/*,.cache/*", Device: "usb"}
+	if !reflect.DeepEqual(home, want) {
+		t.Errorf("zadanie home: %+v, oczekiwane %+v", home, want)
+	}
+	args := Command(c, c.Job("root"))
+	wantArgs := []string{"snapshot", "-logfile=/var/log/backup.log", "-linkdest=2", "-src=/", "-dst=/backup/root"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Command: %q, oczekiwane %q", args, wantArgs)
+	}
+
+	bad := []string{
+		"jobs = 0\n",
+		"[a]\nsrc = /\n",
+		"[a]\nsrc = /\ndst = /b\n[a]\nsrc = /\ndst = /c\n",
+		"[a]\nsrc = /\ndst = /b\nfoo = bar\n",
+		"[a b]\n",
+		"src\n",
+		"retry = 0\n",
+		"[a]\nsrc = /\ndst = /b\nschedule = 61 * * * *\n",
+	}
+	for _, conf := range bad {
+		if _, err := parseConfig(strings.NewReader(conf), "backup.conf"); err == nil {
+			t.Errorf("parseConfig(%q): brak błędu", conf)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	// polecenie zapisuje liczbę równolegle wykonywanych wszystkich
+	// zadań i zadań z tym samym -dst (katalogów w state) i kończy
+	// się błędem dla -src=/fail
+	tmp := t.TempDir()
+	state := filepath.Join(tmp, "state")
+	err := os.Mkdir(state, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+dev=${3#-dst=/}
+mkdir "$1/$dev.$$"
+echo $(ls "$1" | wc -l) $(ls "$1" | grep -c "^$dev") >> "$1.log"
+echo "start $3"
+sleep 0.3
+rmdir "$1/$dev.$$"
+test "$2" != "-src=/fail"
+`
+	cmd := filepath.Join(tmp, "snapshot")
+	err = os.WriteFile(cmd, []byte(script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{Jobs: 3, DeviceJobs: 2, Snapshot: cmd, Options: []string{state}}
+	for i, dev := range []string{"a", "a", "a", "b", "b"} {
+		src := "/src"
+		if i == 4 {
+			src = "/fail"
+		}
+		c.List = append(c.List, &Job{Name: "job" + strconv.Itoa(i), Src: src, Dst: "/" + dev, Device: dev})
+	}
+	results := Run(c, c.List)
+
+	data, err := os.ReadFile(state + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	max, maxDevice := 0, 0
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var n, d int
+		fmt.Sscan(line, &n, &d)
+		if n > max {
+			max = n
+		}
+		if d > maxDevice {
+			maxDevice = d
+		}
+	}
+	if max != 3 || maxDevice != 2 {
+		t.Errorf("maksymalna liczba równoległych zadań: %d (na urządzenie: %d), oczekiwana 3 (2)", max, maxDevice)
+	}
+	for i, r := range results {
+		if (r.Err != nil) != (i == 4) {
+			t.Errorf("zadanie %s: błąd %v", r.Job.Name, r.Err)
+		}
+	}
+	if !strings.Contains(out.String(), "[job3] start -dst=/b\n") {
+		t.Errorf("brak wyjścia zadania z prefiksem:\n%s", out.String())
+	}
+	if n := Report(results); n != 1 {
+		t.Errorf("Report: zakończone błędem: %d, oczekiwane 1", n)
+	}
+}
+
+func TestLockJob(t *testing.T) {
+	c := &Config{LockDir: t.TempDir()}
+	job := &Job{Name: "home"}
+	unlock, err := lockJob(c, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockJob(c, job); err != ErrLocked {
+		t.Errorf("druga blokada: %v, oczekiwany ErrLocked", err)
+	}
+	if u, err := lockJob(c, &Job{Name: "root"}); err != nil {
+		t.Errorf("blokada innego zadania: %s", err)
+	} else {
+		u()
+	}
+	unlock()
+	if u, err := lockJob(c, job); err != nil {
+		t.Errorf("blokada po zwolnieniu: %s", err)
+	} else {
+		u()
+	}
+
+	// symlink zamiast pliku blokady nie jest otwierany
+	target := filepath.Join(t.TempDir(), "target")
+	err = os.Symlink(target, filepath.Join(c.LockDir, "backup-usr.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := lockJob(c, &Job{Name: "usr"}); err == nil {
+		u()
+		t.Errorf("blokada przez symlink nie zwróciła błędu")
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Errorf("plik wskazywany przez symlink został utworzony: %v", err)
+	}
+	err = os.Mkdir(filepath.Join(c.LockDir, "backup-var.lock"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := lockJob(c, &Job{Name: "var"}); err == nil {
+		u()
+		t.Errorf("blokada katalogu nie zwróciła błędu")
+	}
+}
+
+func TestJobStateDue(t *testing.T) {
+	sched, err := ParseSchedule("30 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d, h, m int) time.Time {
+		return time.Date(2015, 2, d, h, m, 0, 0, time.Local)
+	}
+	st := &jobState{job: &Job{Name: "home", Schedule: sched}}
+	if !st.due(day(10, 12, 0)) {
+		t.Errorf("zadanie bez snapshotu powinno być wykonane od razu")
+	}
+	st.update(day(9, 3, 30))
+	if st.due(day(10, 3, 29)) || !st.due(day(10, 3, 30)) {
+		t.Errorf("błędny termin zadania: %v", st.next())
+	}
+	// komputer był wyłączony przez kilka dni
+	if !st.due(day(13, 9, 0)) {
+		t.Errorf("pominięte zadanie nie jest wykonywane")
+	}
+	// starszy snapshot nie zmienia czasu ostatniego wykonania
+	st.update(day(1, 0, 0))
+	if !st.last.Equal(day(9, 3, 30)) {
+		t.Errorf("czas ostatniego wykonania: %v", st.last)
+	}
+	// ponowienie po błędzie
+	st.failed(errors.New("błąd"), day(13, 9, 0), time.Hour)
+	if st.due(day(13, 9, 30)) || !st.due(day(13, 10, 0)) {
+		t.Errorf("błędny termin ponowienia: %v", st.next())
+	}
+	// zadanie wykonywane przez inny proces - następny termin z
+	// harmonogramu zamiast ponowienia
+	st.failed(ErrLocked, day(13, 10, 0), time.Hour)
+	if st.due(day(13, 11, 0)) || st.due(day(14, 3, 29)) || !st.due(day(14, 3, 30)) {
+		t.Errorf("błędny termin po ErrLocked: %v", st.next())
+	}
+}
+
+func TestLastSuccess(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+	oldLocal := time.Local
+	time.Local = time.FixedZone("CET", 3600)
+	defer func() { time.Local = oldLocal }()
+
+	// snapshot z czasem UTC w nazwie bez strefy czasowej i snapshot
+	// w formacie, którego snapshot.ParseID nie rozpoznaje bez opcji
+	// -naming
+	dst := t.TempDir()
+	custom := t.TempDir()
+	for _, d := range []struct{ dir, name string }{
+		{dst, "2015-02-10T17:07:39"},
+		{custom, "20150210-170739"},
+	} {
+		err := os.Mkdir(filepath.Join(d.dir, d.name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Mkdir(filepath.Join(d.dir, ".meta"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(d.dir, ".meta", d.name), []byte("status: complete\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dst    string
+		global []string // opcje dla wszystkich zadań
+		opts   []string // opcje zadania
+		want   time.Time
+	}{
+		{dst, nil, nil, time.Date(2015, 2, 10, 17, 7, 39, 0, time.Local)},
+		{dst, nil, []string{"-naming=utc:2006-01-02T15:04:05"}, time.Date(2015, 2, 10, 17, 7, 39, 0, time.UTC)},
+		{dst, []string{"-naming", "utc:2006-01-02T15:04:05"}, nil, time.Date(2015, 2, 10, 17, 7, 39, 0, time.UTC)},
+		{dst, []string{"-naming=utc:2006-01-02T15:04:05"}, []string{"--naming=local"}, time.Date(2015, 2, 10, 17, 7, 39, 0, time.Local)},
+		{custom, nil, nil, time.Time{}},
+		{custom, nil, []string{"-naming=20060102-150405"}, time.Date(2015, 2, 10, 17, 7, 39, 0, time.Local)},
+		{custom, []string{"-naming=utc:20060102-150405"}, nil, time.Date(2015, 2, 10, 17, 7, 39, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		c := &Config{Options: test.global}
+		job := &Job{Name: "home", Dst: test.dst, Options: test.opts}
+		got := lastSuccess(c, job)
+		if !got.Equal(test.want) {
+			t.Errorf("lastSuccess(%q) z opcjami %q %q = %v, oczekiwany %v",
+				filepath.Base(test.dst), test.global, test.opts, got, test.want)
+		}
+	}
+}
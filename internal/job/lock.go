@@ -0,0 +1,75 @@
+// 2026-10-18 adbr
+
+package job
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ErrLocked oznacza, że zadanie jest już wykonywane przez inny proces.
+var ErrLocked = errors.New("zadanie jest już wykonywane")
+
+// ErrNoLockDir oznacza, że nie ma katalogu plików blokad zadań.
+var ErrNoLockDir = errors.New("brak katalogu plików blokad: ustaw lockdir w pliku konfiguracyjnym " +
+	"lub zmienną środowiskową XDG_RUNTIME_DIR")
+
+// Stała rootLockDir jest domyślnym katalogiem plików blokad zadań dla
+// roota. Katalog należy do roota i inni użytkownicy nie mogą w nim
+// tworzyć plików, więc nie mogą podstawić symlinku zamiast pliku
+// blokady.
+const rootLockDir = "/var/run"
+
+// DefaultLockDir zwraca domyślny katalog plików blokad zadań: dla
+// roota rootLockDir, a dla innych użytkowników katalog ze zmiennej
+// środowiskowej XDG_RUNTIME_DIR (np. /run/user/1000), który należy do
+// użytkownika i tylko on może w nim tworzyć pliki. Zwraca "", jeśli
+// użytkownik nie jest rootem, a XDG_RUNTIME_DIR nie jest ustawiona.
+func DefaultLockDir() string {
+	if os.Geteuid() == 0 {
+		return rootLockDir
+	}
+	return os.Getenv("XDG_RUNTIME_DIR")
+}
+
+// lockJob zakłada blokadę (flock(2)) na pliku blokady zadania job w
+// katalogu c.LockDir i zwraca funkcję zdejmującą blokadę. Jeśli
+// blokadę trzyma inny proces, zwraca błąd ErrLocked, a jeśli nie ma
+// katalogu plików blokad - ErrNoLockDir. Blokada jest zwalniana przez
+// system również po zakończeniu procesu. Plik blokady jest otwierany
+// bez podążania za symlinkiem i musi być zwykłym plikiem.
+func lockJob(c *Config, job *Job) (unlock func(), err error) {
+	dir := c.LockDir
+	if dir == "" {
+		dir = DefaultLockDir()
+	}
+	if dir == "" {
+		return nil, ErrNoLockDir
+	}
+	file := filepath.Join(dir, "backup-"+job.Name+".lock")
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|syscall.O_NOFOLLOW, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("plik blokady %q nie jest zwykłym plikiem", file)
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, ErrLocked
+		}
+		return nil, &os.PathError{Op: "flock", Path: file, Err: err}
+	}
+	return func() { f.Close() }, nil
+}
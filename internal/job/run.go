@@ -0,0 +1,281 @@
+// 2026-10-18 adbr
+
+package job
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adbr/backup/internal/snapshot"
+)
+
+// Zmienna Output jest miejscem, gdzie są wypisywane komunikaty
+// programu i wyjście zadań.
+var Output io.Writer = os.Stdout
+
+// outputMutex synchronizuje wypisywanie do Output z wielu zadań.
+var outputMutex sync.Mutex
+
+// Typ Result zawiera wynik wykonania zadania.
+type Result struct {
+	Job   *Job
+	Begin time.Time
+	End   time.Time
+	Err   error // nil jeśli zadanie zakończyło się sukcesem
+
+	device string // urządzenie, na które zapisuje zadanie
+}
+
+// Run wykonuje zadania jobs z konfiguracji c i zwraca ich wyniki w
+// kolejności jobs. Zadania są uruchamiane w kolejności jobs, ale
+// zadanie czeka, jeśli wykonuje się już c.Jobs zadań albo c.DeviceJobs
+// zadań zapisujących na to samo urządzenie (Device). Wyjście zadania
+// jest wypisywane do Output wierszami z prefiksem "[nazwa] ".
+func Run(c *Config, jobs []*Job) []*Result {
+	r := newRunner(c)
+	results := make([]*Result, len(jobs))
+	devices := make([]string, len(jobs))
+	for i, job := range jobs {
+		devices[i] = Device(job)
+	}
+
+	started := make([]bool, len(jobs))
+	for finished := 0; finished < len(jobs); finished++ {
+		for i, job := range jobs {
+			if started[i] || !r.canStart(job, devices[i]) {
+				continue
+			}
+			started[i] = true
+			results[i] = r.start(job, devices[i])
+		}
+		r.finish(<-r.done)
+	}
+	return results
+}
+
+// Typ runner uruchamia zadania z ograniczeniem liczby wszystkich zadań
+// i zadań zapisujących na to samo urządzenie. Wyniki zakończonych
+// zadań są wysyłane do kanału done i muszą być przekazane do finish.
+type runner struct {
+	c         *Config
+	maxJobs   int
+	maxDevice int
+	running   int
+	perDevice map[string]int
+	busy      map[*Job]bool // wykonywane zadania
+	done      chan *Result
+}
+
+func newRunner(c *Config) *runner {
+	r := &runner{
+		c:         c,
+		maxJobs:   c.Jobs,
+		maxDevice: c.DeviceJobs,
+		perDevice: make(map[string]int),
+		busy:      make(map[*Job]bool),
+		done:      make(chan *Result),
+	}
+	if r.maxJobs < 1 {
+		r.maxJobs = 1
+	}
+	if r.maxDevice < 1 {
+		r.maxDevice = 1
+	}
+	return r
+}
+
+// canStart sprawdza czy można teraz uruchomić zadanie job zapisujące na
+// urządzenie device.
+func (r *runner) canStart(job *Job, device string) bool {
+	return !r.busy[job] && r.running < r.maxJobs && r.perDevice[device] < r.maxDevice
+}
+
+// start uruchamia w tle zadanie job zapisujące na urządzenie device.
+func (r *runner) start(job *Job, device string) *Result {
+	r.running++
+	r.perDevice[device]++
+	r.busy[job] = true
+	res := &Result{Job: job, Begin: time.Now(), device: device}
+	logf("=== start zadania %q (urządzenie: %s)", job.Name, device)
+	go func() {
+		res.Err = runJob(r.c, job)
+		res.End = time.Now()
+		r.done <- res
+	}()
+	return res
+}
+
+// finish rejestruje zakończenie zadania z wynikiem res.
+func (r *runner) finish(res *Result) {
+	r.running--
+	r.perDevice[res.device]--
+	delete(r.busy, res.Job)
+	if res.Err != nil {
+		logf("=== koniec zadania %q: błąd: %s", res.Job.Name, res.Err)
+	} else {
+		logf("=== koniec zadania %q: ok, czas trwania: %s", res.Job.Name, res.End.Sub(res.Begin))
+	}
+}
+
+// Report wypisuje do Output podsumowanie wyników zadań results i
+// zwraca liczbę zadań zakończonych błędem.
+func Report(results []*Result) int {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+
+	failed := 0
+	tw := tabwriter.NewWriter(Output, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "zadanie\tstatus\tczas trwania\n")
+	for _, r := range results {
+		status := "ok"
+		if r.Err != nil {
+			status = "błąd: " + r.Err.Error()
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Job.Name, status, r.End.Sub(r.Begin).Round(time.Second))
+	}
+	tw.Flush()
+	fmt.Fprintf(Output, "zadania: %d, zakończone błędem: %d\n", len(results), failed)
+	return failed
+}
+
+// Command zwraca argumenty polecenia snapshot dla zadania job.
+func Command(c *Config, job *Job) []string {
+	args := []string{c.Snapshot}
+	args = append(args, c.Options...)
+	args = append(args, job.Options...)
+	args = append(args, "-src="+job.Src, "-dst="+job.Dst)
+	if job.Exclude != "" {
+		args = append(args, "-exclude="+job.Exclude)
+	}
+	return args
+}
+
+// NamingLayout zwraca format nazw snapshotów zadania job z konfiguracji
+// c i informację, czy czas w nazwie jest czasem UTC - według ostatniej
+// opcji -naming polecenia snapshot w c.Options i job.Options, a bez
+// tej opcji według snapshot.NameLayout i snapshot.NameUTC.
+func NamingLayout(c *Config, job *Job) (layout string, utc bool, err error) {
+	var spec string
+	opts := append(append([]string(nil), c.Options...), job.Options...)
+	for i, opt := range opts {
+		name := strings.TrimLeft(opt, "-")
+		if name == opt {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "naming="):
+			spec = strings.TrimPrefix(name, "naming=")
+		case name == "naming" && i+1 < len(opts):
+			spec = opts[i+1]
+		}
+	}
+	if spec == "" {
+		return snapshot.NameLayout, snapshot.NameUTC, nil
+	}
+	return snapshot.ParseNaming(spec)
+}
+
+// runJob wykonuje polecenie snapshot dla zadania job. Zadanie nie jest
+// wykonywane (zwracany jest błąd ErrLocked), jeśli to samo zadanie jest
+// już wykonywane przez inny proces.
+func runJob(c *Config, job *Job) error {
+	unlock, err := lockJob(c, job)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	args := Command(c, job)
+	cmd := exec.Command(args[0], args[1:]...)
+	w := &prefixWriter{prefix: "[" + job.Name + "] "}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Run()
+	w.flush()
+	return err
+}
+
+// Device zwraca nazwę urządzenia, na które zapisuje zadanie job: pole
+// Device, a jeśli jest puste - nazwę hosta dla zdalnego katalogu Dst
+// lub numer urządzenia (st_dev) lokalnego katalogu Dst albo jego
+// najbliższego istniejącego katalogu nadrzędnego.
+func Device(job *Job) string {
+	if job.Device != "" {
+		return job.Device
+	}
+	dst := job.Dst
+	if snapshot.IsRemote(dst) {
+		dst = strings.TrimPrefix(dst, "rsync://")
+		if i := strings.IndexAny(dst, ":/"); i >= 0 {
+			dst = dst[:i]
+		}
+		return "host:" + dst
+	}
+	dir := filepath.Clean(dst)
+	for {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+				return fmt.Sprintf("dev:%d", uint64(st.Dev))
+			}
+			return "dir:" + dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "dir:" + filepath.Clean(dst)
+		}
+		dir = parent
+	}
+}
+
+// logf wypisuje do Output sformatowany komunikat programu.
+func logf(format string, args ...interface{}) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fmt.Fprintf(Output, "backup: "+format+"\n", args...)
+}
+
+// Typ prefixWriter wypisuje do Output całe wiersze z prefiksem prefix.
+// Niepełny ostatni wiersz jest buforowany do następnego wywołania
+// Write albo flush.
+type prefixWriter struct {
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	lines := strings.Split(string(w.buf[:i]), "\n")
+	w.buf = append(w.buf[:0], w.buf[i+1:]...)
+
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	for _, line := range lines {
+		_, err := fmt.Fprintf(Output, "%s%s\n", w.prefix, line)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush wypisuje niepełny ostatni wiersz.
+func (w *prefixWriter) flush() {
+	if len(w.buf) > 0 {
+		w.Write([]byte("\n"))
+	}
+}
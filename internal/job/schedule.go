@@ -0,0 +1,210 @@
+// 2026-10-18 adbr
+
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Typ Schedule określa, kiedy zadanie powinno być wykonywane.
+type Schedule interface {
+	// Next zwraca najbliższy po t czas wykonania zadania.
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// ParseSchedule parsuje harmonogram s, który może mieć postać:
+//
+//	every duration		co podany czas (jak w time.ParseDuration),
+//				np. "every 6h"
+//	@hourly, @daily,	co godzinę, codziennie o północy, w
+//	@weekly, @monthly	niedzielę o północy, pierwszego dnia
+//				miesiąca o północy
+//	m h dom mon dow		jak w crontab(5): minuta, godzina, dzień
+//				miesiąca, miesiąc i dzień tygodnia (0 -
+//				niedziela), np. "30 3 * * *"
+func ParseSchedule(s string) (Schedule, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(s, "every ")))
+		if err != nil {
+			return nil, fmt.Errorf("harmonogram %q: %s", s, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("harmonogram %q: odstęp krótszy niż minuta", s)
+		}
+		return interval(d), nil
+	}
+	spec := s
+	switch s {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+	c, err := parseCron(spec)
+	if err != nil {
+		return nil, fmt.Errorf("harmonogram %q: %s", s, err)
+	}
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("harmonogram %q: brak pasujących dat", s)
+	}
+	c.spec = s
+	return c, nil
+}
+
+// Typ interval jest harmonogramem "every duration".
+type interval time.Duration
+
+func (d interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+func (d interval) String() string {
+	return "every " + time.Duration(d).String()
+}
+
+// Typ cron jest harmonogramem w postaci crontab(5). Pola zawierają
+// zbiory dozwolonych wartości.
+type cron struct {
+	spec   string
+	minute [60]bool
+	hour   [24]bool
+	dom    [32]bool
+	month  [13]bool
+	dow    [7]bool
+	anyDom bool // pole dzień miesiąca jest "*"
+	anyDow bool // pole dzień tygodnia jest "*"
+}
+
+// parseCron parsuje harmonogram w postaci crontab(5).
+func parseCron(spec string) (*cron, error) {
+	f := strings.Fields(spec)
+	if len(f) != 5 {
+		return nil, fmt.Errorf("oczekiwane 5 pól")
+	}
+	c := &cron{spec: spec, anyDom: f[2] == "*", anyDow: f[4] == "*"}
+	fields := []struct {
+		set      []bool
+		min, max int
+	}{
+		{c.minute[:], 0, 59},
+		{c.hour[:], 0, 23},
+		{c.dom[:], 1, 31},
+		{c.month[:], 1, 12},
+		{nil, 0, 7}, // 7 - też niedziela
+	}
+	for i, fl := range fields {
+		set := fl.set
+		if set == nil {
+			set = make([]bool, 8)
+		}
+		err := parseCronField(f[i], set, fl.min, fl.max)
+		if err != nil {
+			return nil, err
+		}
+		if fl.set == nil {
+			copy(c.dow[:], set)
+			c.dow[0] = c.dow[0] || set[7]
+		}
+	}
+	return c, nil
+}
+
+// parseCronField parsuje pole harmonogramu crontab(5) s (listę
+// elementów "*", "n", "n-m" z opcjonalnym krokiem "/k") i ustawia
+// dozwolone wartości w set.
+func parseCronField(s string, set []bool, min, max int) error {
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return fmt.Errorf("błędny krok w %q", item)
+			}
+			step = n
+			item = item[:i]
+		}
+		lo, hi := min, max
+		if item != "*" {
+			a := strings.SplitN(item, "-", 2)
+			var err error
+			lo, err = strconv.Atoi(a[0])
+			if err != nil {
+				return fmt.Errorf("błędna wartość %q", item)
+			}
+			hi = lo
+			if len(a) == 2 {
+				hi, err = strconv.Atoi(a[1])
+				if err != nil {
+					return fmt.Errorf("błędna wartość %q", item)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return fmt.Errorf("wartość %q poza zakresem %d-%d", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// Next zwraca najbliższy po t czas (z dokładnością do minuty) zgodny z
+// harmonogramem. Tak jak w cron(8), jeśli ograniczone są oba pola dzień
+// miesiąca i dzień tygodnia, to wystarczy zgodność jednego z nich.
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// harmonogram niemożliwy do spełnienia (np. 31 lutego) -
+	// koniec szukania po kilku latach
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches sprawdza czy dzień t jest zgodny z polami dzień miesiąca i
+// dzień tygodnia.
+func (c *cron) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[t.Weekday()]
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+func (c *cron) String() string {
+	return c.spec
+}
@@ -0,0 +1,56 @@
+// 2026-10-18 adbr
+
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	// 2015-02-10 to wtorek
+	from := time.Date(2015, 2, 10, 18, 7, 39, 0, time.Local)
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"every 6h", from.Add(6 * time.Hour)},
+		{"every 1h30m", from.Add(90 * time.Minute)},
+		{"@hourly", time.Date(2015, 2, 10, 19, 0, 0, 0, time.Local)},
+		{"@daily", time.Date(2015, 2, 11, 0, 0, 0, 0, time.Local)},
+		{"@weekly", time.Date(2015, 2, 15, 0, 0, 0, 0, time.Local)},
+		{"@monthly", time.Date(2015, 3, 1, 0, 0, 0, 0, time.Local)},
+		{"30 3 * * *", time.Date(2015, 2, 11, 3, 30, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2015, 2, 10, 18, 15, 0, 0, time.Local)},
+		{"0 9-17/4 * * 1-5", time.Date(2015, 2, 11, 9, 0, 0, 0, time.Local)},
+		{"0 12 * * 7", time.Date(2015, 2, 15, 12, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2016, 2, 29, 0, 0, 0, 0, time.Local)},
+		// dzień miesiąca lub dzień tygodnia
+		{"0 0 13 * 5", time.Date(2015, 2, 13, 0, 0, 0, 0, time.Local)},
+		{"0 0 12 * 6", time.Date(2015, 2, 12, 0, 0, 0, 0, time.Local)},
+		{"5,10 20 * * *", time.Date(2015, 2, 10, 20, 5, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		s, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %s", test.spec, err)
+			continue
+		}
+		next := s.Next(from)
+		if !next.Equal(test.next) {
+			t.Errorf("%q: Next: %v, oczekiwany %v", test.spec, next, test.next)
+		}
+	}
+
+	bad := []string{
+		"", "every", "every 10s", "every x",
+		"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *",
+		"@yearly", "0 0 31 2 *",
+	}
+	for _, spec := range bad {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q): brak błędu", spec)
+		}
+	}
+}
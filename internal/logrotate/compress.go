@@ -0,0 +1,164 @@
+// 2026-10-18 adbr
+
+package logrotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Nazwy poleceń używanych do kompresji w formatach innych niż gzip
+// (gzip jest obsługiwany przez pakiet compress/gzip).
+var (
+	ZstdCommand  = "zstd"
+	XzCommand    = "xz"
+	Bzip2Command = "bzip2"
+)
+
+// Typ codec opisuje format kompresji zarchiwizowanych plików.
+type codec struct {
+	name string // nazwa formatu, np. "gzip"
+	ext  string // rozszerzenie pliku, np. ".gz"
+	// polecenie kompresujące stdin na stdout lub nil dla gzip
+	command *string
+}
+
+// Zmienna codecs zawiera obsługiwane formaty kompresji.
+var codecs = []codec{
+	{"gzip", ".gz", nil},
+	{"zstd", ".zst", &ZstdCommand},
+	{"xz", ".xz", &XzCommand},
+	{"bzip2", ".bz2", &Bzip2Command},
+}
+
+// codecByName zwraca format kompresji o nazwie name.
+func codecByName(name string) (*codec, error) {
+	for i := range codecs {
+		if codecs[i].name == name {
+			return &codecs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("nieznany format kompresji %q", name)
+}
+
+// codecByExt zwraca format kompresji dla rozszerzenia pliku ext lub
+// nil jeśli ext nie jest rozszerzeniem pliku skompresowanego.
+func codecByExt(ext string) *codec {
+	for i := range codecs {
+		if codecs[i].ext == ext {
+			return &codecs[i]
+		}
+	}
+	return nil
+}
+
+// compress zapisuje do w dane z r skompresowane w formacie c. Argument
+// fi opisuje plik źródłowy; gzip zapisuje w nagłówku jego nazwę i czas
+// modyfikacji.
+func (c *codec) compress(w io.Writer, r io.Reader, fi os.FileInfo) error {
+	if c.command == nil {
+		return writeGzip(w, r, fi.Name(), fi)
+	}
+	cmd := exec.Command(*c.command, "-q", "-c")
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	info("polecenie: %q", cmd.Args)
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s: %s", *c.command, err)
+	}
+	return nil
+}
+
+// compressLogFile kompresuje plik z logami file (bez rozszerzenia ext)
+// w formacie c do pliku z rozszerzeniem c.ext i usuwa plik file. Plik
+// skompresowany jest najpierw zapisywany do pliku tymczasowego (z
+// nazwą zaczynającą się od kropki, więc nie jest traktowany jak
+// archiwum), zapisywany na dysk (fsync) i dopiero potem jego nazwa
+// jest zmieniana na docelową. Plik źródłowy jest usuwany po zapisaniu
+// na dysk katalogu, więc po przerwaniu programu istnieje co najmniej
+// jeden kompletny plik.
+func compressLogFile(file *logFile, c *codec) error {
+	src := logFileName(file)
+	dst := logFileName(&logFile{name: file.name, num: file.num, ext: c.ext})
+	info("kompresja %q -> %q", src, dst)
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	dir, base := filepath.Split(dst)
+	tmp := filepath.Join(dir, "."+base+".tmp")
+	err = os.Remove(tmp)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	err = c.compress(out, in, fi)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, fi.ModTime(), fi.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("kompresja %q: %s", src, err)
+	}
+
+	err = syncDir(dir)
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// writeGzip zapisuje do w dane z r skompresowane przez gzip. W
+// nagłówku jest zapisywana nazwa i czas modyfikacji pliku źródłowego.
+func writeGzip(w io.Writer, r io.Reader, name string, fi os.FileInfo) error {
+	zw := gzip.NewWriter(w)
+	zw.Name = name
+	zw.ModTime = fi.ModTime()
+	_, err := io.Copy(zw, r)
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// syncDir zapisuje na dysk katalog dir (zmiany nazw plików).
+func syncDir(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+// 2026-10-18 adbr
+
+package logrotate
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Zmienna CopyTruncate włącza rotację przez skopiowanie pliku z logami
+// do pliku z numerem 0 i obcięcie oryginalnego pliku do zera, zamiast
+// zmiany jego nazwy. Jest potrzebna dla programów, które trzymają plik
+// z logami otwarty i nie umieją go otworzyć ponownie. Dane zapisane do
+// logu między kopiowaniem a obcięciem pliku są tracone.
+var CopyTruncate = false
+
+// afterCopy jest wywoływana przez copyTruncate po skopiowaniu pliku, a
+// przed jego obcięciem (testy dopisują w niej dane do logu).
+var afterCopy = func() {}
+
+// copyTruncate kopiuje zawartość pliku z logami file do pliku new i
+// obcina plik file do zera. Kopia jest zapisywana do pliku
+// tymczasowego, zapisywana na dysk i dopiero potem jej nazwa jest
+// zmieniana na new, więc plik file jest obcinany tylko po udanym
+// skopiowaniu. Zwraca liczbę bajtów, które zostały zapisane do file po
+// skopiowaniu, a przed obcięciem pliku (i zostały utracone).
+func copyTruncate(file, new string) (lost int64, err error) {
+	f, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	dir, base := filepath.Split(new)
+	tmp := filepath.Join(dir, "."+base+".tmp")
+	err = os.Remove(tmp)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, f)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, fi.ModTime(), fi.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, new)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	err = syncDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	afterCopy()
+
+	// rozmiar pliku tuż przed obcięciem - to, co zostało dopisane
+	// po skopiowaniu, jest tracone
+	fi, err = f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	err = f.Truncate(0)
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() > n {
+		lost = fi.Size() - n
+	}
+	return lost, nil
+}
@@ -4,22 +4,36 @@
 // przekroczeniu zadanego rozmiaru pliku. Przechowywana jest określona
 // liczba poprzednio zarchiwizowanych plików. Archiwizacja polega na
 // zmianie nazwy pliku, np: file.log -> file.log.0, file.log.0 ->
-// file.log.1, itd.
+// file.log.1, itd. Zarchiwizowane pliki mogą być kompresowane.
 package logrotate
 
 import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Zmienna Verbose włącza logowanie na stdout komunikatów o
 // wykonywanych czynnościach.
 var Verbose = false
 
+// Zmienna Compress włącza kompresję zarchiwizowanych plików w
+// formacie Codec, np: file.log.0 -> file.log.0.gz.
+var Compress = false
+
+// Zmienna Codec określa format kompresji: "gzip", "zstd", "xz" lub
+// "bzip2". Zmiana formatu dotyczy tylko nowo kompresowanych plików -
+// archiwa w innych formatach są rotowane bez zmian.
+var Codec = "gzip"
+
+// Zmienna DelayCompress opóźnia kompresję pliku file.log.0 do
+// następnej rotacji (wtedy jest kompresowany jako file.log.1). Ma
+// znaczenie tylko razem z Compress.
+var DelayCompress = false
+
 // Typ logFile reprezentuje składowe nazwy pliku z logami. Na przykład
 // logFile{name: "filename.log", num: 1, ext: ".gz"} odpowiada nazwie
 // pliku "filename.log.1.gz".
@@ -33,13 +47,14 @@ type logFile struct {
 // częściami składowymi nazwy. Na przykład dla pliku
 // "filename.log.1.gz" zwróci logFile{name: "filename.log", num: 1,
 // ext: ".gz"}. Rozszeszenie z numerem pliku musi wystąpić. Opcjonalne
-// rozszerzenie pliku skompresowanego może być tylko ".gz".
+// rozszerzenie pliku skompresowanego może być jednym z rozszerzeń
+// formatów kompresji: ".gz", ".zst", ".xz" lub ".bz2".
 func parseLogFile(file string) (*logFile, error) {
 	var logfile logFile
 
-	// rozszerzenie .gz (opcjonalne)
+	// rozszerzenie pliku skompresowanego (opcjonalne)
 	ext := filepath.Ext(file)
-	if ext == ".gz" {
+	if codecByExt(ext) != nil {
 		logfile.ext = ext
 		file = file[:len(file)-len(ext)]
 	}
@@ -60,19 +75,55 @@ func parseLogFile(file string) (*logFile, error) {
 	return &logfile, nil
 }
 
-// Rotate sprawdza czy plik file ma rozmiar większy od size, i jeśli
-// tak to robi rotację plików z logami zachowując num najnowszych
-// plików. Jeśli size = 0 to plik nie jest rotowany; jeśli num = 0 to
-// nie ma ograniczenia na liczbę archiwizowanych plików.
+// Rotate sprawdza czy plik file ma rozmiar większy od size albo czy
+// minął czas rotacji Interval (i plik ma co najmniej MinSize bajtów), i
+// jeśli tak to robi rotację plików z logami zachowując num najnowszych
+// plików. Jeśli size = 0 i Interval jest pusty to plik nie jest
+// rotowany; jeśli num = 0 to nie ma ograniczenia na liczbę
+// archiwizowanych plików. Jeśli Compress jest true to po rotacji są
+// kompresowane nieskompresowane archiwa. Jeśli CopyTruncate jest true
+// to plik jest kopiowany i obcinany zamiast zmiany nazwy. Przed rotacją
+// jest wykonywane polecenie PreRotate, a po rotacji program piszący do
+// logu jest powiadamiany (PidFile, PostRotate). Na końcu (także bez
+// rotacji) są usuwane archiwa przekraczające MaxAge i MaxTotal.
 func Rotate(file string, size int64, num int) error {
+	return rotate(file, size, num, time.Now())
+}
+
+// rotate działa tak jak Rotate dla bieżącego czasu now.
+func rotate(file string, size int64, num int, now time.Time) error {
+	err := checkInterval(Interval)
+	if err != nil {
+		return err
+	}
+	var c *codec
+	if Compress {
+		var err error
+		c, err = codecByName(Codec)
+		if err != nil {
+			return err
+		}
+	}
+	sig, err := checkSignal(Signal)
+	if err != nil {
+		return err
+	}
+
 	// sprawdzenie czy plik jest gotowy do archiwizacji
-	ok, err := isReady(file, size)
+	ok, err := isReady(file, size, now)
 	if err != nil {
 		return err
 	}
 	if !ok {
 		info("plik %q nie jest gotowy do rotacji", file)
-		return nil
+		return expireLogFiles(file, now)
+	}
+
+	if PreRotate != "" {
+		err := runCommand("prerotate", PreRotate, file)
+		if err != nil {
+			return fmt.Errorf("rotacja %q przerwana: %s", file, err)
+		}
 	}
 
 	// wczytanie nazw plików zarchiwizowanych
@@ -82,13 +133,17 @@ func Rotate(file string, size int64, num int) error {
 	}
 
 	// sortowanie plików według numerów
-	sort.Slice(a, func(i, j int) bool {
-		return a[i].num < a[j].num
-	})
+	sortLogFiles(a)
 
-	// rotacja plików - zaczynając od ostatniego
+	// rotacja plików - zaczynając od ostatniego; pliki, które po
+	// rotacji miałyby numer num lub większy, są usuwane (także
+	// pozostałe po rotacjach z większym num)
 	for i := len(a) - 1; i >= 0; i-- {
 		if num > 0 && a[i].num >= num-1 {
+			err := removeLogFile(a[i], fmt.Sprintf("limit liczby archiwów: %d", num))
+			if err != nil {
+				return err
+			}
 			continue
 		}
 		err := renameLogFile(a[i])
@@ -97,45 +152,126 @@ func Rotate(file string, size int64, num int) error {
 		}
 	}
 
-	// zmiana nazwy głównego pliku z logami
 	new := file + ".0"
-	info("%q -> %q", file, new)
-	err = os.Rename(file, new)
+	if CopyTruncate {
+		// skopiowanie i obcięcie głównego pliku z logami
+		info("kopiowanie %q -> %q i obcięcie %q", file, new, file)
+		lost, err := copyTruncate(file, new)
+		if err != nil {
+			return err
+		}
+		if lost > 0 {
+			notice("%d bajtów zapisanych do %q w czasie kopiowania mogło zostać utraconych", lost, file)
+		} else {
+			info("brak zapisów do %q w czasie kopiowania", file)
+		}
+	} else {
+		// zmiana nazwy głównego pliku z logami
+		info("%q -> %q", file, new)
+		err = os.Rename(file, new)
+		if err != nil {
+			return err
+		}
+
+		// utworzenie pustego pliku - truncate file
+		info("utworzenie pustego pliku %q", file)
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	if Interval != "" {
+		err := saveRotation(file, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	// błąd powiadomienia nie przerywa kompresji i usuwania archiwów
+	nerr := notifyWriter(file, sig)
+
+	if Compress {
+		err := compressLogFiles(file, c)
+		if err != nil {
+			return err
+		}
+	}
+	err = expireLogFiles(file, now)
 	if err != nil {
 		return err
 	}
+	return nerr
+}
 
-	// utworzenie pustego pliku - truncate file
-	info("utworzenie pustego pliku %q", file)
-	f, err := os.Create(file)
+// compressLogFiles kompresuje nieskompresowane archiwa pliku z logami
+// file w formacie c, z wyjątkiem file.0, jeśli DelayCompress jest
+// true.
+func compressLogFiles(file string, c *codec) error {
+	a, err := globLogFiles(file)
 	if err != nil {
 		return err
 	}
-	f.Close()
-
+	first := 0
+	if DelayCompress {
+		first = 1
+	}
+	for _, f := range a {
+		if f.ext != "" || f.num < first {
+			continue
+		}
+		err := compressLogFile(f, c)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// isReady sprawdza czy plik z logami jest gotowy do archiwizacji.
-func isReady(file string, size int64) (bool, error) {
-	info, err := os.Stat(file)
+// isReady sprawdza czy plik z logami jest gotowy do archiwizacji w
+// chwili now: czy ma co najmniej size bajtów (jeśli size > 0) albo czy
+// od ostatniej rotacji minął czas Interval i plik ma co najmniej
+// MinSize bajtów. Jeśli w pliku stanu nie ma czasu ostatniej rotacji,
+// to jest zapisywany czas now, a rotacja według czasu jest wykonywana
+// dopiero po upływie Interval.
+func isReady(file string, size int64, now time.Time) (bool, error) {
+	fi, err := os.Stat(file)
 	if err != nil {
 		return false, err
 	}
-	if size <= 0 {
+	if size > 0 && fi.Size() >= size {
+		info("rozmiar pliku %q: %d >= %d", file, fi.Size(), size)
+		return true, nil
+	}
+	if Interval == "" {
 		return false, nil
 	}
-	if info.Size() >= size {
-		return true, nil
+
+	last, ok, err := lastRotation(file)
+	if err != nil {
+		return false, err
 	}
-	return false, nil
+	if !ok {
+		info("brak czasu ostatniej rotacji pliku %q - zapisanie bieżącego czasu", file)
+		return false, saveRotation(file, now)
+	}
+	if !isDue(Interval, last, now) {
+		return false, nil
+	}
+	if fi.Size() < MinSize {
+		info("rozmiar pliku %q: %d < %d (minsize)", file, fi.Size(), MinSize)
+		return false, nil
+	}
+	info("ostatnia rotacja pliku %q: %s (%s)", file, last.Format(time.RFC3339), Interval)
+	return true, nil
 }
 
 // renameLogFile zmienia nazwę pliku z logami file na nazwę z numerem
 // o 1 większym.
 func renameLogFile(file *logFile) error {
-	old := fmt.Sprintf("%s.%d%s", file.name, file.num, file.ext)
-	new := fmt.Sprintf("%s.%d%s", file.name, file.num+1, file.ext)
+	old := logFileName(file)
+	new := logFileName(&logFile{name: file.name, num: file.num + 1, ext: file.ext})
 	info("%q -> %q", old, new)
 	err := os.Rename(old, new)
 	if err != nil {
@@ -144,6 +280,11 @@ func renameLogFile(file *logFile) error {
 	return nil
 }
 
+// logFileName zwraca nazwę pliku z logami file, np. "filename.log.1.gz".
+func logFileName(file *logFile) string {
+	return fmt.Sprintf("%s.%d%s", file.name, file.num, file.ext)
+}
+
 // globLogFile zwraca slice z plikami pasującymi do wzorca "file.*" i
 // spełniającymi warunki parseLogFile, czyli archiwami pliku z logami
 // file.
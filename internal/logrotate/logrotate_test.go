@@ -3,8 +3,20 @@
 package logrotate
 
 import (
+	"compress/bzip2"
+	"compress/gzip"
 	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestParseLogFile(t *testing.T) {
@@ -72,6 +84,34 @@ func TestParseLogFile(t *testing.T) {
 			nil,
 		},
 
+		{
+			"file.log.3.zst",
+			&logFile{
+				name: "file.log",
+				num:  3,
+				ext:  ".zst",
+			},
+			nil,
+		},
+		{
+			"file.log.10.xz",
+			&logFile{
+				name: "file.log",
+				num:  10,
+				ext:  ".xz",
+			},
+			nil,
+		},
+		{
+			"file.log.0.bz2",
+			&logFile{
+				name: "file.log",
+				num:  0,
+				ext:  ".bz2",
+			},
+			nil,
+		},
+
 		// Przypadki błędne - funkcja powinna zwrócić błąd:
 
 		{
@@ -104,6 +144,11 @@ func TestParseLogFile(t *testing.T) {
 			nil,
 			errParse,
 		},
+		{
+			"file.log.1.bz", // nieznane rozszerzenie
+			nil,
+			errParse,
+		},
 	}
 
 	for _, test := range tests {
@@ -125,3 +170,426 @@ func TestParseLogFile(t *testing.T) {
 		}
 	}
 }
+
+func TestRotateCompress(t *testing.T) {
+	defer func() { Compress, DelayCompress = false, false }()
+	Compress, DelayCompress = true, true
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.log")
+	for i, content := range []string{"log 1\n", "log 2\n", "log 3\n"} {
+		err := os.WriteFile(file, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 {
+			DelayCompress = false
+		}
+		err = Rotate(file, 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if _, err := os.Stat(file + ".0"); err != nil {
+				t.Errorf("delaycompress: %s", err)
+			}
+		}
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	want := []string{file, file + ".0.gz", file + ".1.gz", file + ".2.gz"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("pliki po rotacji: %q, oczekiwane %q", names, want)
+	}
+	for i, content := range []string{"log 3\n", "log 2\n", "log 1\n"} {
+		f, err := os.Open(want[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: %q, oczekiwane %q", want[i+1], data, content)
+		}
+	}
+}
+
+func TestRotateCodecs(t *testing.T) {
+	if _, err := exec.LookPath(Bzip2Command); err != nil {
+		t.Skip(err)
+	}
+	defer func() { Compress, Codec = false, "gzip" }()
+	Compress, Codec = true, "bzip2"
+
+	// archiwa w różnych formatach, np. po zmianach formatu
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.log")
+	for _, name := range []string{"", ".0.zst", ".1.gz", ".2.xz", ".3.bz2"} {
+		err := os.WriteFile(file+name, []byte("log"+name+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := Rotate(file, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	want := []string{file, file + ".0.bz2", file + ".1.zst", file + ".2.gz", file + ".3.xz", file + ".4.bz2"}
+	sort.Strings(want)
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("pliki po rotacji: %q, oczekiwane %q", names, want)
+	}
+	data, err := os.ReadFile(file + ".1.zst")
+	if err != nil || string(data) != "log.0.zst\n" {
+		t.Errorf("%s: %q %v", file+".1.zst", data, err)
+	}
+	f, err := os.Open(file + ".0.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err = io.ReadAll(bzip2.NewReader(f))
+	if err != nil || string(data) != "log\n" {
+		t.Errorf("%s: %q %v", file+".0.bz2", data, err)
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	// 2015-02-10 to wtorek
+	last := time.Date(2015, 2, 10, 18, 30, 0, 0, time.Local)
+	tests := []struct {
+		interval string
+		now      time.Time
+		due      bool
+	}{
+		{"hourly", time.Date(2015, 2, 10, 18, 59, 0, 0, time.Local), false},
+		{"hourly", time.Date(2015, 2, 10, 19, 0, 0, 0, time.Local), true},
+		{"daily", time.Date(2015, 2, 10, 23, 59, 0, 0, time.Local), false},
+		{"daily", time.Date(2015, 2, 11, 0, 1, 0, 0, time.Local), true},
+		{"weekly", time.Date(2015, 2, 15, 23, 0, 0, 0, time.Local), false},
+		{"weekly", time.Date(2015, 2, 16, 0, 0, 0, 0, time.Local), true},
+		{"monthly", time.Date(2015, 2, 28, 23, 0, 0, 0, time.Local), false},
+		{"monthly", time.Date(2015, 3, 1, 0, 0, 0, 0, time.Local), true},
+		{"36h", time.Date(2015, 2, 12, 6, 29, 0, 0, time.Local), false},
+		{"36h", time.Date(2015, 2, 12, 6, 30, 0, 0, time.Local), true},
+	}
+	for _, test := range tests {
+		if due := isDue(test.interval, last, test.now); due != test.due {
+			t.Errorf("isDue(%q, %v, %v) = %v, oczekiwane %v", test.interval, last, test.now, due, test.due)
+		}
+	}
+}
+
+func TestRotateInterval(t *testing.T) {
+	defer func() { Interval, MinSize = "", 0 }()
+	Interval, MinSize = "daily", 1
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.log")
+	rotated := func() bool {
+		_, err := os.Stat(file + ".0")
+		return err == nil
+	}
+	day := func(d, h int) time.Time {
+		return time.Date(2015, 2, d, h, 0, 0, 0, time.Local)
+	}
+	err := os.WriteFile(file, []byte("log\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pierwsze uruchomienie zapisuje tylko czas w pliku stanu
+	err = rotate(file, 0, 0, day(10, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated() {
+		t.Fatalf("rotacja bez czasu ostatniej rotacji")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".backup.log.state")); err != nil {
+		t.Fatal(err)
+	}
+	err = rotate(file, 0, 0, day(10, 23))
+	if err != nil || rotated() {
+		t.Fatalf("rotacja w tym samym dniu: %v", err)
+	}
+	err = rotate(file, 0, 0, day(11, 1))
+	if err != nil || !rotated() {
+		t.Fatalf("brak rotacji w następnym dniu: %v", err)
+	}
+
+	// pusty plik nie jest rotowany (minsize), ale plik większy od
+	// size jest rotowany niezależnie od czasu
+	err = rotate(file, 0, 0, day(12, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file + ".1"); err == nil {
+		t.Errorf("rotacja pustego pliku")
+	}
+	err = os.WriteFile(file, []byte("log\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rotate(file, 2, 0, day(12, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file + ".1"); err != nil {
+		t.Errorf("brak rotacji według rozmiaru: %s", err)
+	}
+	last, ok, err := lastRotation(file)
+	if err != nil || !ok || !last.Equal(day(12, 2)) {
+		t.Errorf("czas ostatniej rotacji: %v %v %v", last, ok, err)
+	}
+}
+
+func TestRotateRetention(t *testing.T) {
+	defer func() { MaxAge, MaxTotal = 0, 0 }()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.log")
+	write := func(name, content string) {
+		err := os.WriteFile(name, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := func() []string {
+		names, err := filepath.Glob(file + ".*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	// archiwa pozostałe po rotacjach z większym -num są usuwane
+	write(file, "log\n")
+	for _, n := range []string{".0", ".1", ".2", ".3", ".4.gz"} {
+		write(file+n, "log"+n+"\n")
+	}
+	err := Rotate(file, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{file + ".0", file + ".1", file + ".2"}
+	if names := files(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("-num: %q, oczekiwane %q", names, want)
+	}
+	for i, content := range []string{"log\n", "log.0\n", "log.1\n"} {
+		data, err := os.ReadFile(want[i])
+		if err != nil || string(data) != content {
+			t.Errorf("%s: %q %v, oczekiwane %q", want[i], data, err, content)
+		}
+	}
+
+	// -maxage - także bez rotacji
+	now := time.Now()
+	old := now.Add(-10 * 24 * time.Hour)
+	err = os.Chtimes(file+".2", old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	MaxAge = 7 * 24 * time.Hour
+	err = Rotate(file, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{file + ".0", file + ".1"}
+	if names := files(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("-maxage: %q, oczekiwane %q", names, want)
+	}
+
+	// -maxtotal - usuwane są najstarsze archiwa
+	MaxTotal = 7
+	err = Rotate(file, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{file + ".0"}
+	if names := files(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("-maxtotal: %q, oczekiwane %q", names, want)
+	}
+}
+
+func TestCopyTruncate(t *testing.T) {
+	defer func() { CopyTruncate = false }()
+	CopyTruncate = true
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.log")
+	err := os.WriteFile(file, []byte("log 1\n"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// program piszący do logu ma otwarty plik
+	w, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	err = Rotate(file, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.WriteString("log 2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || string(data) != "log 2\n" {
+		t.Errorf("%s: %q %v", file, data, err)
+	}
+	data, err = os.ReadFile(file + ".0")
+	if err != nil || string(data) != "log 1\n" {
+		t.Errorf("%s: %q %v", file+".0", data, err)
+	}
+	fi, err := os.Stat(file + ".0")
+	if err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("%s: %v %v", file+".0", fi.Mode(), err)
+	}
+}
+
+func TestCopyTruncateLost(t *testing.T) {
+	defer func() { afterCopy = func() {} }()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.log")
+	err := os.WriteFile(file, []byte("log 1\n"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// program dopisuje do logu między kopiowaniem a obcięciem
+	afterCopy = func() {
+		_, err := w.WriteString("log 2\nlog 3\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	lost, err := copyTruncate(file, file+".0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lost != 12 {
+		t.Errorf("utracone bajty: %d, oczekiwane 12", lost)
+	}
+	data, err := os.ReadFile(file + ".0")
+	if err != nil || string(data) != "log 1\n" {
+		t.Errorf("%s: %q %v", file+".0", data, err)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 0 {
+		t.Errorf("%s: rozmiar po obcięciu %d, oczekiwany 0", file, fi.Size())
+	}
+
+	// bez dopisanych danych nic nie jest tracone
+	afterCopy = func() {}
+	_, err = w.WriteString("log 4\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, err = copyTruncate(file, file+".1")
+	if err != nil || lost != 0 {
+		t.Errorf("copyTruncate: utracone bajty %d %v, oczekiwane 0", lost, err)
+	}
+}
+
+func TestRotateNotify(t *testing.T) {
+	defer func() { PreRotate, PostRotate, PidFile, CommandTimeout = "", "", "", time.Minute }()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.log")
+	err := os.WriteFile(file, []byte("log\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+
+	// nieudane polecenie prerotate przerywa rotację
+	PreRotate = "exit 1"
+	if err := Rotate(file, 1, 0); err == nil {
+		t.Errorf("brak błędu polecenia prerotate")
+	}
+	if _, err := os.Stat(file + ".0"); err == nil {
+		t.Errorf("rotacja po błędzie polecenia prerotate")
+	}
+
+	PreRotate = `echo "pre $1" >> ` + out
+	PostRotate = `ls "$1.0" >> ` + out
+	err = Rotate(file, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "pre " + file + "\n" + file + ".0\n"
+	if string(data) != want {
+		t.Errorf("polecenia: %q, oczekiwane %q", data, want)
+	}
+
+	// limit czasu polecenia
+	PreRotate, PostRotate = "", "sleep 5"
+	CommandTimeout = 100 * time.Millisecond
+	err = os.WriteFile(file, []byte("log\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Rotate(file, 1, 0); err == nil {
+		t.Errorf("brak błędu po przekroczeniu limitu czasu")
+	}
+
+	// sygnał do procesu z pliku pidfile
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	defer signal.Stop(sig)
+	PostRotate, Signal = "", "USR1"
+	defer func() { Signal = "HUP" }()
+	PidFile = filepath.Join(dir, "pid")
+	err = os.WriteFile(PidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(file, []byte("log\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Rotate(file, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sig:
+	case <-time.After(5 * time.Second):
+		t.Errorf("brak sygnału SIGUSR1")
+	}
+}
@@ -0,0 +1,131 @@
+// 2026-10-18 adbr
+
+package logrotate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Zmienna PidFile jest nazwą pliku z numerem procesu programu
+// piszącego do logu. Jeśli nie jest pusta, to po rotacji do procesu
+// jest wysyłany sygnał Signal, żeby program otworzył log ponownie.
+var PidFile = ""
+
+// Zmienna Signal jest nazwą sygnału wysyłanego do procesu z PidFile:
+// "HUP", "USR1" lub "USR2".
+var Signal = "HUP"
+
+// Zmienne PreRotate i PostRotate zawierają polecenia powłoki
+// wykonywane przed i po rotacji pliku. Polecenie dostaje nazwę pliku z
+// logami jako argument $1. Nieudane polecenie PreRotate przerywa
+// rotację.
+var (
+	PreRotate  = ""
+	PostRotate = ""
+)
+
+// Zmienna CommandTimeout określa maksymalny czas wykonania poleceń
+// PreRotate i PostRotate. Po jego przekroczeniu polecenie jest
+// zabijane.
+var CommandTimeout = time.Minute
+
+// signals zawiera sygnały, które można wysłać do procesu z PidFile.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// checkSignal sprawdza poprawność nazwy sygnału name i zwraca sygnał.
+// Nazwa może mieć prefiks "SIG".
+func checkSignal(name string) (syscall.Signal, error) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("nieobsługiwany sygnał %q", name)
+	}
+	return sig, nil
+}
+
+// signalProcess wysyła sygnał sig do procesu o numerze zapisanym w
+// pliku pidfile.
+func signalProcess(pidfile string, sig syscall.Signal) error {
+	data, err := os.ReadFile(pidfile)
+	if err != nil {
+		return err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("%s: błędny numer procesu %q", pidfile, strings.TrimSpace(string(data)))
+	}
+	info("wysłanie sygnału %s do procesu %d (%s)", sig, pid, pidfile)
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	err = p.Signal(sig)
+	if err != nil {
+		return fmt.Errorf("sygnał %s do procesu %d (%s): %s", sig, pid, pidfile, err)
+	}
+	return nil
+}
+
+// runCommand wykonuje polecenie powłoki command (opisane w komunikatach
+// jako name) z argumentem $1 równym file i z limitem czasu
+// CommandTimeout. Wyjście polecenia jest wypisywane wierszami z
+// prefiksem "name: ".
+func runCommand(name, command, file string) error {
+	ctx := context.Background()
+	if CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, CommandTimeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command, "sh", file)
+	// po przekroczeniu limitu czasu jest zabijana cała grupa
+	// procesów polecenia, także procesy uruchomione przez powłokę
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	info("%s: polecenie: %q", name, command)
+	err := cmd.Run()
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if line != "" {
+			notice("%s: %s", name, line)
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s: przekroczony limit czasu %s", name, CommandTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return nil
+}
+
+// notifyWriter powiadamia program piszący do logu file o rotacji:
+// wysyła sygnał do procesu z PidFile i wykonuje polecenie PostRotate.
+func notifyWriter(file string, sig syscall.Signal) error {
+	if PidFile != "" {
+		err := signalProcess(PidFile, sig)
+		if err != nil {
+			return err
+		}
+	}
+	if PostRotate != "" {
+		return runCommand("postrotate", PostRotate, file)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// 2026-10-18 adbr
+
+package logrotate
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Zmienna MaxAge określa maksymalny wiek zarchiwizowanego pliku (czas
+// od jego ostatniej modyfikacji). Starsze archiwa są usuwane. Zero
+// oznacza brak ograniczenia.
+var MaxAge time.Duration = 0
+
+// Zmienna MaxTotal określa maksymalny łączny rozmiar (w bajtach)
+// wszystkich zarchiwizowanych plików. Po jego przekroczeniu są
+// usuwane najstarsze archiwa. Zero oznacza brak ograniczenia.
+var MaxTotal int64 = 0
+
+// expireLogFiles usuwa archiwa pliku z logami file starsze niż MaxAge
+// w chwili now, a potem najstarsze archiwa, dopóki łączny rozmiar
+// archiwów jest większy niż MaxTotal.
+func expireLogFiles(file string, now time.Time) error {
+	if MaxAge <= 0 && MaxTotal <= 0 {
+		return nil
+	}
+	a, err := globLogFiles(file)
+	if err != nil {
+		return err
+	}
+	sortLogFiles(a)
+
+	var kept []*logFile
+	var sizes []int64
+	var total int64
+	for _, f := range a {
+		fi, err := os.Stat(logFileName(f))
+		if err != nil {
+			return err
+		}
+		if MaxAge > 0 && now.Sub(fi.ModTime()) > MaxAge {
+			reason := fmt.Sprintf("starsze niż %s", MaxAge)
+			err := removeLogFile(f, reason)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, f)
+		sizes = append(sizes, fi.Size())
+		total += fi.Size()
+	}
+
+	if MaxTotal <= 0 {
+		return nil
+	}
+	for i := len(kept) - 1; i >= 0 && total > MaxTotal; i-- {
+		reason := fmt.Sprintf("łączny rozmiar archiwów %d > %d", total, MaxTotal)
+		err := removeLogFile(kept[i], reason)
+		if err != nil {
+			return err
+		}
+		total -= sizes[i]
+	}
+	return nil
+}
+
+// removeLogFile usuwa archiwum file i loguje powód usunięcia reason.
+func removeLogFile(file *logFile, reason string) error {
+	name := logFileName(file)
+	notice("usunięcie %q (%s)", name, reason)
+	return os.Remove(name)
+}
+
+// sortLogFiles sortuje archiwa według numerów (od najnowszego).
+func sortLogFiles(a []*logFile) {
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].num != a[j].num {
+			return a[i].num < a[j].num
+		}
+		return a[i].ext < a[j].ext
+	})
+}
+
+// notice drukuje sformatowany komunikat do stdout niezależnie od
+// Verbose - dla czynności, które zawsze powinny być widoczne w logu,
+// np. usunięcia plików. Dodaje prefiks i znak nowego wiersza.
+func notice(format string, args ...interface{}) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	fmt.Printf("logrotate: "+format, args...)
+}
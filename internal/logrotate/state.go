@@ -0,0 +1,168 @@
+// 2026-10-18 adbr
+
+package logrotate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Zmienna Interval określa rotację według czasu: "hourly", "daily",
+// "weekly" lub "monthly" - rotacja w pierwszym uruchomieniu w nowej
+// godzinie, dniu, tygodniu (od poniedziałku) lub miesiącu, albo czas
+// (jak w time.ParseDuration, np. "36h") - rotacja, jeśli od ostatniej
+// rotacji minął ten czas. Pusta wartość wyłącza rotację według czasu.
+var Interval = ""
+
+// Zmienna MinSize określa minimalny rozmiar pliku (w bajtach) dla
+// rotacji według czasu. Nie dotyczy rotacji według rozmiaru.
+var MinSize int64 = 0
+
+// Zmienna StateFile jest nazwą pliku z czasami ostatnich rotacji
+// logów. Jeśli jest pusta, to dla pliku z logami dir/file.log jest
+// używany plik dir/.file.log.state. Jeden plik stanu może być wspólny
+// dla wielu logów.
+var StateFile = ""
+
+// checkInterval sprawdza poprawność wartości Interval.
+func checkInterval(interval string) error {
+	switch interval {
+	case "", "hourly", "daily", "weekly", "monthly":
+		return nil
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("błędny czas rotacji %q", interval)
+	}
+	return nil
+}
+
+// isDue sprawdza czy od ostatniej rotacji w chwili last do chwili now
+// minął czas rotacji interval.
+func isDue(interval string, last, now time.Time) bool {
+	switch interval {
+	case "":
+		return false
+	case "hourly", "daily", "weekly", "monthly":
+		last = last.In(now.Location())
+		return periodStart(interval, now).After(periodStart(interval, last))
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return false
+	}
+	return now.Sub(last) >= d
+}
+
+// periodStart zwraca początek godziny, dnia, tygodnia lub miesiąca
+// (zależnie od interval), w którym jest czas t.
+func periodStart(interval string, t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch interval {
+	case "hourly":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case "weekly":
+		// tydzień zaczyna się w poniedziałek
+		d -= (int(t.Weekday()) + 6) % 7
+	case "monthly":
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// stateFileName zwraca nazwę pliku stanu dla pliku z logami file.
+func stateFileName(file string) string {
+	if StateFile != "" {
+		return StateFile
+	}
+	dir, base := filepath.Split(file)
+	return filepath.Join(dir, "."+base+".state")
+}
+
+// stateKey zwraca nazwę pliku z logami file używaną w pliku stanu.
+func stateKey(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	return abs
+}
+
+// readState wczytuje plik stanu name: czasy ostatnich rotacji logów.
+// Każdy wiersz pliku ma postać "czas plik", gdzie czas jest w formacie
+// RFC 3339. Brak pliku nie jest błędem.
+func readState(name string) (map[string]time.Time, error) {
+	state := make(map[string]time.Time)
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: błędny wiersz %q", name, n, line)
+		}
+		t, err := time.Parse(time.RFC3339, line[:i])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", name, n, err)
+		}
+		state[line[i+1:]] = t
+	}
+	return state, scanner.Err()
+}
+
+// lastRotation zwraca czas ostatniej rotacji pliku z logami file
+// zapisany w pliku stanu. Jeśli czasu nie ma, to ok jest false.
+func lastRotation(file string) (last time.Time, ok bool, err error) {
+	state, err := readState(stateFileName(file))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	last, ok = state[stateKey(file)]
+	return last, ok, nil
+}
+
+// saveRotation zapisuje w pliku stanu czas t jako czas ostatniej
+// rotacji pliku z logami file. Plik stanu jest zamieniany atomowo
+// (zapis do pliku tymczasowego i zmiana nazwy).
+func saveRotation(file string, t time.Time) error {
+	name := stateFileName(file)
+	state, err := readState(name)
+	if err != nil {
+		return err
+	}
+	state[stateKey(file)] = t
+
+	files := make([]string, 0, len(state))
+	for f := range state {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	var b strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&b, "%s %s\n", state[f].Format(time.RFC3339), f)
+	}
+	tmp := name + ".tmp"
+	err = os.WriteFile(tmp, []byte(b.String()), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, name)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stałe określające wynik sprawdzenia Check. Wartości są kodami
+// wyjścia wtyczek Nagios/Icinga.
+const (
+	CheckOK       = 0
+	CheckWarning  = 1
+	CheckCritical = 2
+	CheckUnknown  = 3
+)
+
+// Typ Health zawiera wynik sprawdzenia aktualności snapshotów w
+// katalogu Dst.
+type Health struct {
+	Dst     string
+	State   int           // CheckOK, CheckWarning, CheckCritical lub CheckUnknown
+	Meta    *Meta         // metadane najnowszego kompletnego snapshotu lub nil
+	Time    time.Time     // czas najnowszego kompletnego snapshotu
+	Age     time.Duration // wiek najnowszego kompletnego snapshotu
+	Message string        // opis wyniku
+}
+
+// Check sprawdza aktualność snapshotów w katalogu dst (lokalnym lub
+// zdalnym): wynik jest CheckWarning, jeśli najnowszy kompletny snapshot
+// jest starszy niż warn, i CheckCritical, jeśli jest starszy niż crit
+// albo w dst nie ma kompletnych snapshotów. Jeśli dst nie można
+// odczytać, wynik jest CheckUnknown. Czasem snapshotu jest czas z jego
+// nazwy.
+func Check(dst string, warn, crit time.Duration) *Health {
+	return check(dst, globalNaming(), warn, crit, time.Now())
+}
+
+// CheckLayout działa tak jak Check dla snapshotów z nazwami w formacie
+// layout (z czasem UTC, jeśli utc jest true) zamiast NameLayout i
+// NameUTC - np. snapshotów zadania programu backup z własną opcją
+// -naming.
+func CheckLayout(dst, layout string, utc bool, warn, crit time.Duration) *Health {
+	return check(dst, naming{layout, utc}, warn, crit, time.Now())
+}
+
+// check działa tak jak Check dla nazw snapshotów w formacie n i
+// bieżącego czasu now.
+func check(dst string, n naming, warn, crit time.Duration, now time.Time) *Health {
+	h := &Health{Dst: dst}
+	m, err := NewestCompleteLayout(dst, n.layout, n.utc)
+	if err != nil {
+		h.State = CheckUnknown
+		h.Message = err.Error()
+		return h
+	}
+	if m == nil {
+		h.State = CheckCritical
+		h.Message = "brak kompletnych snapshotów"
+		return h
+	}
+	h.Meta = m
+	h.Time = m.End
+	if id, err := n.parseID(m.Snapshot); err == nil {
+		h.Time = id.Time
+	}
+	h.Age = now.Sub(h.Time)
+
+	switch {
+	case h.Age < 0:
+		h.State = CheckWarning
+		h.Message = fmt.Sprintf("najnowszy snapshot %s ma czas w przyszłości", m.Snapshot)
+		return h
+	case h.Age > crit:
+		h.State = CheckCritical
+	case h.Age > warn:
+		h.State = CheckWarning
+	default:
+		h.State = CheckOK
+	}
+	h.Message = fmt.Sprintf("najnowszy snapshot %s (%s temu)", m.Snapshot, FormatAge(h.Age))
+	if m.Errors != "" && m.Errors != "brak" {
+		h.Message += fmt.Sprintf(", błędy rsync: %s", m.Errors)
+	}
+	return h
+}
+
+// FormatAge zwraca czas d w postaci "2d3h", "5h12m" lub "12m".
+func FormatAge(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// StateName zwraca nazwę wyniku sprawdzenia state, np. "WARNING".
+func StateName(state int) string {
+	switch state {
+	case CheckOK:
+		return "OK"
+	case CheckWarning:
+		return "WARNING"
+	case CheckCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// WorstState zwraca gorszy z wyników sprawdzenia a i b. Od
+// najgorszego: CheckCritical, CheckUnknown, CheckWarning, CheckOK.
+func WorstState(a, b int) int {
+	rank := func(state int) int {
+		switch state {
+		case CheckOK:
+			return 0
+		case CheckWarning:
+			return 1
+		case CheckUnknown:
+			return 2
+		}
+		return 3
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
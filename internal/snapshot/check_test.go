@@ -0,0 +1,110 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	dst := t.TempDir()
+	// najnowszy snapshot jest niekompletny
+	for _, name := range []string{"2015-02-09T03:30:00", "2015-02-10T03:30:00", "2015-02-11T03:30:00"} {
+		dir := filepath.Join(dst, name)
+		err := os.Mkdir(dir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := StatusComplete
+		if name == "2015-02-11T03:30:00" {
+			status = "partial"
+		}
+		err = writeMeta(localTransport{}, dst, name, &Meta{Status: status, Errors: "vanished: 1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	warn, crit := 26*time.Hour, 50*time.Hour
+	tests := []struct {
+		now   time.Time
+		state int
+	}{
+		{time.Date(2015, 2, 11, 5, 0, 0, 0, time.Local), CheckOK},
+		{time.Date(2015, 2, 11, 6, 0, 0, 0, time.Local), CheckWarning},
+		{time.Date(2015, 2, 12, 6, 0, 0, 0, time.Local), CheckCritical},
+		{time.Date(2015, 2, 10, 3, 0, 0, 0, time.Local), CheckWarning},
+	}
+	for _, test := range tests {
+		h := check(dst, globalNaming(), warn, crit, test.now)
+		if h.State != test.state {
+			t.Errorf("check w chwili %v: stan %s, oczekiwany %s (%s)",
+				test.now, StateName(h.State), StateName(test.state), h.Message)
+		}
+	}
+	h := check(dst, globalNaming(), warn, crit, time.Date(2015, 2, 11, 5, 0, 0, 0, time.Local))
+	if h.Meta == nil || h.Meta.Snapshot != "2015-02-10T03:30:00" || h.Age != 25*time.Hour+30*time.Minute {
+		t.Errorf("check: %+v", h)
+	}
+	if !strings.Contains(h.Message, "(1d1h temu), błędy rsync: vanished: 1") {
+		t.Errorf("check: komunikat %q", h.Message)
+	}
+
+	if h := check(t.TempDir(), globalNaming(), warn, crit, time.Now()); h.State != CheckCritical {
+		t.Errorf("check bez snapshotów: stan %s", StateName(h.State))
+	}
+	if h := check(filepath.Join(dst, "nie-ma"), globalNaming(), warn, crit, time.Now()); h.State != CheckUnknown {
+		t.Errorf("check nieistniejącego katalogu: stan %s", StateName(h.State))
+	}
+	if s := WorstState(CheckUnknown, CheckWarning); s != CheckUnknown {
+		t.Errorf("WorstState: %s", StateName(s))
+	}
+	if s := WorstState(CheckUnknown, CheckCritical); s != CheckCritical {
+		t.Errorf("WorstState: %s", StateName(s))
+	}
+}
+
+func TestCheckLayout(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	// snapshoty z nazwami w formacie innym niż NameLayout, jak w
+	// zadaniu z opcją -naming=20060102-150405
+	dst := t.TempDir()
+	for _, name := range []string{"20150209-033000", "20150210-033000"} {
+		err := os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeMeta(localTransport{}, dst, name, &Meta{Status: StatusComplete})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	warn, crit := 26*time.Hour, 50*time.Hour
+	now := time.Date(2015, 2, 11, 5, 0, 0, 0, time.Local)
+	h := check(dst, naming{"20060102-150405", false}, warn, crit, now)
+	if h.State != CheckOK || h.Meta == nil || h.Meta.Snapshot != "20150210-033000" {
+		t.Errorf("check z formatem nazw: stan %s (%s), oczekiwany OK dla 20150210-033000",
+			StateName(h.State), h.Message)
+	}
+	if h.Age != 25*time.Hour+30*time.Minute {
+		t.Errorf("check z formatem nazw: wiek %v, oczekiwany 25h30m", h.Age)
+	}
+	h = check(dst, naming{"20060102-150405", true}, warn, crit, now)
+	if h.Meta == nil || h.Meta.Snapshot != "20150210-033000" {
+		t.Errorf("check z formatem nazw w UTC: %+v", h)
+	}
+	if h := check(dst, globalNaming(), warn, crit, now); h.State == CheckOK {
+		t.Errorf("check bez formatu nazw: stan %s, oczekiwany inny niż OK", StateName(h.State))
+	}
+}
@@ -0,0 +1,327 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RsyncPasswordFile jest plikiem z hasłem do modułu serwera rsync
+// (opcja --password-file), używanym gdy src lub dst jest postaci
+// "rsync://user@host/module/path". Gdy jest pusty, rsync używa
+// zmiennej środowiskowej RSYNC_PASSWORD.
+var RsyncPasswordFile = ""
+
+// Stała daemonPrefix jest prefiksem nazwy katalogu w module serwera
+// rsync.
+const daemonPrefix = "rsync://"
+
+// passwordOption zwraca opcję --password-file dla rsync, jeśli któryś z
+// katalogów locs jest w module serwera rsync i RsyncPasswordFile jest
+// ustawiony.
+func passwordOption(locs ...string) string {
+	if RsyncPasswordFile == "" {
+		return ""
+	}
+	for _, loc := range locs {
+		if isDaemon(loc) {
+			return "--password-file=" + RsyncPasswordFile
+		}
+	}
+	return ""
+}
+
+// Typ daemonTransport wykonuje operacje na katalogu w module serwera
+// rsync (rsync --daemon). Protokół rsync pozwala tylko na kopiowanie
+// plików, więc operacje są wykonywane przez kopiowanie pustego
+// katalogu, pliku lub symlinku z katalogu tymczasowego (Mkdir,
+// WriteFile, Symlink, Replace), kopiowanie z opcją --delete (Remove),
+// rsync --list-only (Stat, ReadDir, Readlink) i kopiowanie pliku do
+// katalogu tymczasowego (ReadFile). Ścieżki są względne wobec katalogu
+// modułu.
+//
+// Zmiana nazwy katalogu nie jest możliwa, więc Rename zwraca błąd, a
+// snapshot jest kopiowany od razu do katalogu z timestampem (patrz
+// Snapshot). Replace kopiuje plik lub symlink oldname na miejsce
+// newname - serwer zastępuje istniejący plik atomowo - i usuwa
+// oldname. Sync nic nie robi - zapisywaniem danych na dysk zajmuje się
+// serwer.
+//
+// Moduł musi mieć ustawione "read only = false" i, jeśli serwer działa
+// bez chroot, "munge symlinks = false", żeby symlink 'last' był
+// zwykłym symlinkiem.
+type daemonTransport struct {
+	url string // "rsync://host/module"
+}
+
+// run uruchamia polecenie rsync z argumentami args (poza opcją
+// --password-file, która jest dodawana) i zwraca jego stdout. Błąd
+// "No such file or directory" jest zamieniany na *os.PathError z
+// operacją op, plikiem name i błędem os.ErrNotExist.
+func (t *daemonTransport) run(op, name string, args ...string) (string, error) {
+	if opt := passwordOption(t.url); opt != "" {
+		args = append([]string{opt}, args...)
+	}
+	cmd := exec.Command(RsyncCommand, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "No such file or directory") {
+			return "", &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", &os.PathError{Op: op, Path: t.location(name), Err: fmt.Errorf("%s", msg)}
+	}
+	return stdout.String(), nil
+}
+
+// location zwraca argument rsync dla pliku name w module.
+func (t *daemonTransport) location(name string) string {
+	return t.url + "/" + strings.TrimPrefix(path.Clean(name), "/")
+}
+
+// list zwraca pliki z wyniku rsync --list-only dla pliku name (dla
+// katalogu z '/' na końcu nazwy - jego zawartość) i cele symlinków.
+// Jeśli follow jest true, to symlinki są rozwijane.
+func (t *daemonTransport) list(op, name string, follow bool) ([]os.FileInfo, []string, error) {
+	args := []string{"--list-only"}
+	if follow {
+		args = append(args, "--copy-links")
+	}
+	loc := t.location(name)
+	if strings.HasSuffix(name, "/") {
+		loc += "/"
+	}
+	out, err := t.run(op, name, append(args, loc)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// linia ma postać "drwxr-xr-x 4,096 2026/10/18 12:00:00 name"
+	// lub dla symlinku "lrwxrwxrwx 19 2026/10/18 12:00:00 name -> target"
+	var infos []os.FileInfo
+	var targets []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) < 2 {
+			continue
+		}
+		rest := strings.Fields(fields[1])
+		if len(rest) < 4 {
+			continue
+		}
+		fname := strings.Join(rest[3:], " ")
+		var target string
+		typ := fields[0][:1]
+		if typ == "l" {
+			if i := strings.Index(fname, " -> "); i >= 0 {
+				fname, target = fname[:i], fname[i+4:]
+			}
+		}
+		if fname == "." {
+			continue
+		}
+		infos = append(infos, newRemoteFileInfo(fname, typ))
+		targets = append(targets, target)
+	}
+	if len(infos) == 0 && !strings.HasSuffix(name, "/") {
+		return nil, nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return infos, targets, nil
+}
+
+// exists sprawdza czy plik name istnieje (nie rozwijając symlinków).
+func (t *daemonTransport) exists(op, name string) (bool, error) {
+	_, _, err := t.list(op, name, false)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// push tworzy tymczasowy lokalny katalog, w którym funkcja prepare
+// przygotowuje pliki do skopiowania do modułu, i uruchamia rsync z
+// argumentami zwróconymi przez prepare.
+func (t *daemonTransport) push(op, name string, prepare func(tmpdir string) ([]string, error)) error {
+	tmpdir, err := os.MkdirTemp("", "snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+	args, err := prepare(tmpdir)
+	if err != nil {
+		return err
+	}
+	_, err = t.run(op, name, args...)
+	return err
+}
+
+func (t *daemonTransport) Mkdir(name string) error {
+	ok, err := t.exists("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	return t.push("mkdir", name, func(tmpdir string) ([]string, error) {
+		err := os.Chmod(tmpdir, 0755)
+		if err != nil {
+			return nil, err
+		}
+		// '/' na końcu - kopiowany jest pusty katalog tmpdir jako
+		// katalog name
+		return []string{"--dirs", "--perms", tmpdir + "/", t.location(name) + "/"}, nil
+	})
+}
+
+func (t *daemonTransport) Rename(oldname, newname string) error {
+	return &os.PathError{Op: "rename", Path: oldname,
+		Err: errors.New("zmiana nazwy nie jest obsługiwana przez serwer rsync")}
+}
+
+func (t *daemonTransport) Replace(oldname, newname string) error {
+	infos, targets, err := t.list("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	link := infos[0].Mode()&os.ModeSymlink != 0
+	if !link {
+		data, err = t.ReadFile(oldname)
+		if err != nil {
+			return err
+		}
+	}
+	err = t.push("rename", newname, func(tmpdir string) ([]string, error) {
+		file := filepath.Join(tmpdir, path.Base(newname))
+		if link {
+			err := os.Symlink(targets[0], file)
+			if err != nil {
+				return nil, err
+			}
+			return []string{"--links", file, t.location(path.Dir(newname)) + "/"}, nil
+		}
+		err := os.WriteFile(file, data, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"--perms", file, t.location(path.Dir(newname)) + "/"}, nil
+	})
+	if err != nil {
+		return err
+	}
+	return t.Remove(oldname)
+}
+
+func (t *daemonTransport) Remove(name string) error {
+	ok, err := t.exists("remove", name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	base := path.Base(name)
+	return t.push("remove", name, func(tmpdir string) ([]string, error) {
+		// z pustego katalogu są kopiowane tylko pliki pasujące do
+		// filtrów, więc --delete usuwa tylko plik name i jego
+		// zawartość
+		return []string{
+			"--recursive", "--delete",
+			"--include=/" + base, "--include=/" + base + "/**", "--exclude=*",
+			tmpdir + "/", t.location(path.Dir(name)) + "/",
+		}, nil
+	})
+}
+
+func (t *daemonTransport) Symlink(oldname, newname string) error {
+	ok, err := t.exists("symlink", newname)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return &os.PathError{Op: "symlink", Path: newname, Err: os.ErrExist}
+	}
+	return t.push("symlink", newname, func(tmpdir string) ([]string, error) {
+		link := filepath.Join(tmpdir, path.Base(newname))
+		err := os.Symlink(oldname, link)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"--links", link, t.location(path.Dir(newname)) + "/"}, nil
+	})
+}
+
+func (t *daemonTransport) Stat(name string) (os.FileInfo, error) {
+	infos, _, err := t.list("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return infos[0], nil
+}
+
+func (t *daemonTransport) ReadDir(name string) ([]os.FileInfo, error) {
+	infos, _, err := t.list("readdir", strings.TrimSuffix(name, "/")+"/", false)
+	return infos, err
+}
+
+func (t *daemonTransport) Readlink(name string) (string, error) {
+	infos, targets, err := t.list("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if infos[0].Mode()&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: fmt.Errorf("nie jest symlinkiem")}
+	}
+	return targets[0], nil
+}
+
+func (t *daemonTransport) ReadFile(name string) ([]byte, error) {
+	tmpdir, err := os.MkdirTemp("", "snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+	_, err = t.run("open", name, t.location(name), tmpdir+"/")
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(tmpdir, path.Base(name)))
+}
+
+func (t *daemonTransport) WriteFile(name string, data []byte) error {
+	return t.push("open", name, func(tmpdir string) ([]string, error) {
+		file := filepath.Join(tmpdir, path.Base(name))
+		err := os.WriteFile(file, data, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"--perms", file, t.location(path.Dir(name)) + "/"}, nil
+	})
+}
+
+func (t *daemonTransport) Sync(name string, tree bool) error {
+	return nil
+}
+
+// Abs zwraca nazwę względem katalogu modułu zaczynającą się od '/' -
+// serwer rsync traktuje katalog modułu jako katalog główny.
+func (t *daemonTransport) Abs(name string) (string, error) {
+	return path.Join("/", name), nil
+}
@@ -0,0 +1,214 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startDaemon uruchamia lokalny serwer rsync z modułem "mod" w
+// katalogu dir, dostępnym dla użytkownika "adbr" z hasłem z pliku
+// RsyncPasswordFile. Zwraca adres modułu "rsync://adbr@host:port/mod".
+// Pomija test, jeśli polecenie rsync nie jest zainstalowane.
+func startDaemon(t *testing.T, dir string) string {
+	if _, err := exec.LookPath(RsyncCommand); err != nil {
+		t.Skipf("brak polecenia %s", RsyncCommand)
+	}
+	tmp := t.TempDir()
+
+	// wolny port na interfejsie loopback
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	secrets := filepath.Join(tmp, "secrets")
+	err = os.WriteFile(secrets, []byte("adbr:tajne\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RsyncPasswordFile = filepath.Join(tmp, "password")
+	err = os.WriteFile(RsyncPasswordFile, []byte("tajne\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { RsyncPasswordFile = "" })
+
+	conf := filepath.Join(tmp, "rsyncd.conf")
+	err = os.WriteFile(conf, []byte(fmt.Sprintf(`use chroot = false
+munge symlinks = false
+uid = %d
+gid = %d
+pid file = %s
+
+[mod]
+path = %s
+read only = false
+auth users = adbr
+secrets file = %s
+`, os.Getuid(), os.Getgid(), filepath.Join(tmp, "rsyncd.pid"), dir, secrets)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(RsyncCommand, "--daemon", "--no-detach",
+		"--address=127.0.0.1", fmt.Sprintf("--port=%d", port), "--config="+conf)
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	for i := 0; ; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			c.Close()
+			break
+		}
+		if i == 50 {
+			t.Fatalf("serwer rsync nie uruchomił się: %s", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Sprintf("rsync://adbr@%s/mod", addr)
+}
+
+func TestDaemonSnapshot(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	mod := t.TempDir()
+	url := startDaemon(t, mod)
+	err := os.Mkdir(filepath.Join(mod, "backup"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := t.TempDir()
+	err = os.WriteFile(filepath.Join(src, "a"), []byte("abc"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := url + "/backup"
+	err = Snapshot(src+"/", dst, "")
+	if err != nil {
+		t.Fatalf("pierwszy Snapshot: %s", err)
+	}
+	// nazwy snapshotów mają dokładność do sekundy
+	time.Sleep(1100 * time.Millisecond)
+	err = Snapshot(src+"/", dst, "")
+	if err != nil {
+		t.Fatalf("drugi Snapshot: %s", err)
+	}
+
+	dir := filepath.Join(mod, "backup")
+	names, err := listSnapshots(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("snapshoty %q, oczekiwane dwa", names)
+	}
+	last, err := os.Readlink(filepath.Join(dir, "last"))
+	if err != nil || last != names[1] {
+		t.Errorf("symlink 'last' wskazuje na %q (%v), oczekiwane %q", last, err, names[1])
+	}
+	if _, err := os.Lstat(filepath.Join(dir, lastTmp)); !os.IsNotExist(err) {
+		t.Errorf("nie usunięty tymczasowy symlink: %v", err)
+	}
+	for _, name := range names {
+		m, err := ReadMeta(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Status != StatusComplete {
+			t.Errorf("snapshot %q ma status %q, oczekiwany %q", name, m.Status, StatusComplete)
+		}
+	}
+	f0, err := os.Stat(filepath.Join(dir, names[0], "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f1, err := os.Stat(filepath.Join(dir, names[1], "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(f0, f1) {
+		t.Errorf("niezmieniony plik nie jest hard linkiem do poprzedniego snapshotu")
+	}
+}
+
+func TestDaemonSrc(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	mod := t.TempDir()
+	url := startDaemon(t, mod)
+	err := os.Mkdir(filepath.Join(mod, "data"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(mod, "data", "a"), []byte("abc"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// src w module serwera rsync, a dst lokalny - w tym samym
+	// module, żeby sprawdzić odczyt snapshotów przez serwer
+	dir := filepath.Join(mod, "backup")
+	err = os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Snapshot(url+"/data/", dir, "")
+	if err != nil {
+		t.Fatalf("pierwszy Snapshot: %s", err)
+	}
+	// nazwy snapshotów mają dokładność do sekundy
+	time.Sleep(1100 * time.Millisecond)
+	err = Snapshot(url+"/data/", dir, "")
+	if err != nil {
+		t.Fatalf("drugi Snapshot: %s", err)
+	}
+
+	names, err := listSnapshots(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("snapshoty %q, oczekiwane dwa", names)
+	}
+	f0, err := os.Stat(filepath.Join(dir, names[0], "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f1, err := os.Stat(filepath.Join(dir, names[1], "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(f0, f1) {
+		t.Errorf("niezmieniony plik nie jest hard linkiem do poprzedniego snapshotu")
+	}
+
+	m, err := NewestComplete(url + "/backup")
+	if err != nil {
+		t.Fatalf("NewestComplete: %s", err)
+	}
+	if m == nil || m.Snapshot != names[1] {
+		t.Errorf("NewestComplete = %+v, oczekiwany snapshot %q", m, names[1])
+	}
+}
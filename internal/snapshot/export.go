@@ -0,0 +1,314 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Nazwy poleceń używanych do kompresji i szyfrowania eksportowanych
+// snapshotów.
+var (
+	ZstdCommand = "zstd"
+	AgeCommand  = "age"
+	GpgCommand  = "gpg"
+)
+
+// Stałe określające rodzaj kompresji eksportowanego snapshotu.
+const (
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Stałe określające rodzaj szyfrowania eksportowanego snapshotu.
+const (
+	EncryptAge = "age"
+	EncryptGpg = "gpg"
+)
+
+// Typ Encryption określa szyfrowanie eksportowanego snapshotu kluczem
+// publicznym. Szyfrowanie wykonuje polecenie age(1) lub gpg(1), więc
+// na komputerze z backupami wystarczy klucz publiczny.
+type Encryption struct {
+	Method     string   // EncryptAge, EncryptGpg lub "" - bez szyfrowania
+	Recipients []string // klucze publiczne age lub identyfikatory kluczy gpg
+	Identity   string   // plik z kluczem prywatnym age (przy imporcie)
+}
+
+// EncryptionFromName zwraca rodzaj szyfrowania odpowiadający
+// rozszerzeniu nazwy pliku file, np. "age" dla "backup.tar.zst.age".
+func EncryptionFromName(file string) string {
+	switch {
+	case strings.HasSuffix(file, ".age"):
+		return EncryptAge
+	case strings.HasSuffix(file, ".gpg"), strings.HasSuffix(file, ".pgp"):
+		return EncryptGpg
+	}
+	return ""
+}
+
+// CompressionFromName zwraca rodzaj kompresji odpowiadający
+// rozszerzeniu nazwy pliku file, np. "gzip" dla "backup.tar.gz".
+// Rozszerzenie pliku zaszyfrowanego jest pomijane.
+func CompressionFromName(file string) string {
+	if EncryptionFromName(file) != "" {
+		file = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+	switch {
+	case strings.HasSuffix(file, ".gz"), strings.HasSuffix(file, ".tgz"):
+		return CompressGzip
+	case strings.HasSuffix(file, ".zst"):
+		return CompressZstd
+	}
+	return CompressNone
+}
+
+// Stała paxMeta jest kluczem rekordu PAX katalogu snapshotu w
+// archiwum tar, w którym są zapisane metadane snapshotu (w postaci
+// takiej jak w pliku w katalogu metaDir).
+const paxMeta = "BACKUP.snapshot.meta"
+
+// Export zapisuje snapshot name z katalogu dst do w w postaci archiwum
+// tar (format PAX) skompresowanego zgodnie z compress i zaszyfrowanego
+// zgodnie z enc. Nazwy plików w archiwum zaczynają się od nazwy
+// snapshotu. Zachowywane są prawa dostępu, właściciel, rozszerzone
+// atrybuty, symlinki i hard linki wewnątrz snapshotu. Metadane
+// snapshotu są zapisywane w rekordzie PAX paxMeta katalogu snapshotu.
+// Archiwum jest zapisywane strumieniowo, bez plików tymczasowych.
+func Export(dst, name string, w io.Writer, compress string, enc Encryption) error {
+	if _, err := ParseID(name); err != nil {
+		return err
+	}
+	dir := filepath.Join(dst, name)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%q nie jest katalogiem", dir)
+	}
+
+	var meta []byte
+	m, err := ReadMeta(dst, name)
+	switch {
+	case err == nil:
+		meta = formatMeta(m)
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	ew, err := encryptWriter(w, enc)
+	if err != nil {
+		return err
+	}
+	cw, err := compressWriter(ew, compress)
+	if err != nil {
+		ew.Close()
+		return err
+	}
+	info("eksport snapshotu %q (kompresja: %s)", name, compress)
+	err = writeTar(cw, dir, name, meta)
+	if err != nil {
+		cw.Close()
+		ew.Close()
+		return err
+	}
+	err = cw.Close()
+	if err != nil {
+		ew.Close()
+		return err
+	}
+	return ew.Close()
+}
+
+// writeTar zapisuje drzewo katalogów dir do w jako archiwum tar.
+// Nazwy plików w archiwum mają prefiks prefix. Jeśli meta nie jest
+// nil, to jest zapisywane w rekordzie PAX paxMeta katalogu dir.
+func writeTar(w io.Writer, dir, prefix string, meta []byte) error {
+	tw := tar.NewWriter(w)
+
+	// pierwsze nazwy plików w archiwum dla i-węzłów z wieloma
+	// linkami - kolejne linki są zapisywane jako hard linki
+	links := make(map[inodeKey]string)
+
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(prefix, rel))
+
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+		if fi.Mode()&os.ModeSocket != 0 {
+			warning("pominięcie gniazda %q", path)
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		hdr.Format = tar.FormatPAX
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if path == dir && meta != nil {
+			hdr.PAXRecords = map[string]string{paxMeta: string(meta)}
+		}
+
+		if fi.Mode().IsRegular() {
+			st, ok := fi.Sys().(*syscall.Stat_t)
+			if ok && st.Nlink > 1 {
+				key := inodeKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+				if first, ok := links[key]; ok {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = first
+					hdr.Size = 0
+				} else {
+					links[key] = name
+				}
+			}
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 {
+			attrs, err := xattrs(path)
+			if err != nil {
+				return err
+			}
+			for k, v := range attrs {
+				if hdr.PAXRecords == nil {
+					hdr.PAXRecords = make(map[string]string)
+				}
+				hdr.PAXRecords["SCHILY.xattr."+k] = v
+			}
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// compressWriter zwraca writer kompresujący dane zapisywane do w.
+// Zamknięcie zwróconego writera kończy kompresję, ale nie zamyka w.
+func compressWriter(w io.Writer, compress string) (io.WriteCloser, error) {
+	switch compress {
+	case CompressNone, "":
+		return nopCloser{w}, nil
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		return commandWriter(w, ZstdCommand, "-q", "-c")
+	}
+	return nil, fmt.Errorf("nieznany rodzaj kompresji %q", compress)
+}
+
+// encryptWriter zwraca writer szyfrujący dane zapisywane do w kluczami
+// publicznymi enc.Recipients. Zamknięcie zwróconego writera kończy
+// szyfrowanie, ale nie zamyka w.
+func encryptWriter(w io.Writer, enc Encryption) (io.WriteCloser, error) {
+	if enc.Method != "" && len(enc.Recipients) == 0 {
+		return nil, fmt.Errorf("brak klucza publicznego do szyfrowania")
+	}
+	var args []string
+	switch enc.Method {
+	case "":
+		return nopCloser{w}, nil
+	case EncryptAge:
+		args = append(args, "-e")
+		for _, r := range enc.Recipients {
+			args = append(args, "-r", r)
+		}
+		return commandWriter(w, AgeCommand, args...)
+	case EncryptGpg:
+		// klucz odbiorcy jest podany wprost, więc nie jest
+		// sprawdzany poziom zaufania do klucza
+		args = append(args, "--batch", "--trust-model", "always", "--encrypt")
+		for _, r := range enc.Recipients {
+			args = append(args, "--recipient", r)
+		}
+		return commandWriter(w, GpgCommand, args...)
+	}
+	return nil, fmt.Errorf("nieznany rodzaj szyfrowania %q", enc.Method)
+}
+
+// Typ nopCloser jest writerem z pustą metodą Close.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// Typ cmdWriter przekazuje zapisywane dane na stdin polecenia
+// systemowego. Close czeka na zakończenie polecenia.
+type cmdWriter struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// commandWriter uruchamia polecenie name z argumentami args, którego
+// stdout jest zapisywany do w, i zwraca writer do jego stdin.
+func commandWriter(w io.Writer, name string, args ...string) (io.WriteCloser, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	info("polecenie: %q", strings.Join(cmd.Args, " "))
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &cmdWriter{cmd: cmd, stdin: stdin}, nil
+}
+
+func (w *cmdWriter) Write(p []byte) (int, error) {
+	return w.stdin.Write(p)
+}
+
+func (w *cmdWriter) Close() error {
+	err := w.stdin.Close()
+	werr := w.cmd.Wait()
+	if werr != nil {
+		return fmt.Errorf("%s: %s", w.cmd.Args[0], werr)
+	}
+	return err
+}
@@ -0,0 +1,318 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromName(t *testing.T) {
+	var tests = []struct {
+		file     string // nazwa pliku z archiwum
+		compress string // oczekiwany rodzaj kompresji
+		encrypt  string // oczekiwany rodzaj szyfrowania
+	}{
+		{"backup.tar", CompressNone, ""},
+		{"backup.tar.gz", CompressGzip, ""},
+		{"backup.tgz", CompressGzip, ""},
+		{"backup.tar.zst", CompressZstd, ""},
+		{"backup.tar.zst.age", CompressZstd, EncryptAge},
+		{"backup.tar.gz.gpg", CompressGzip, EncryptGpg},
+		{"backup.tar.pgp", CompressNone, EncryptGpg},
+		{"-", CompressNone, ""},
+	}
+
+	for _, test := range tests {
+		c := CompressionFromName(test.file)
+		if c != test.compress {
+			t.Errorf("CompressionFromName(%q) = %q, oczekiwane %q", test.file, c, test.compress)
+		}
+		e := EncryptionFromName(test.file)
+		if e != test.encrypt {
+			t.Errorf("EncryptionFromName(%q) = %q, oczekiwane %q", test.file, e, test.encrypt)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	src := t.TempDir()
+	dst := t.TempDir()
+	name := "2015-02-10T18:07:39"
+	dir := filepath.Join(src, name)
+
+	err := os.MkdirAll(filepath.Join(dir, "d"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "d", "a"), []byte("abc"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Link(filepath.Join(dir, "d", "a"), filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("d/a", filepath.Join(dir, "s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &Meta{
+		Status: StatusComplete,
+		Src:    "/home/",
+		Begin:  time.Date(2015, 2, 10, 18, 0, 0, 0, time.UTC),
+		End:    time.Date(2015, 2, 10, 18, 7, 39, 0, time.UTC),
+		Errors: "vanished: 1",
+	}
+	err = writeMeta(localTransport{}, src, name, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = Export(src, name, &buf, CompressGzip, Encryption{})
+	if err != nil {
+		t.Fatalf("Export: %s", err)
+	}
+	got, err := Import(dst, &buf, CompressGzip, Encryption{})
+	if err != nil {
+		t.Fatalf("Import: %s", err)
+	}
+	if got != name {
+		t.Errorf("Import zwrócił nazwę %q, oczekiwane %q", got, name)
+	}
+
+	out := filepath.Join(dst, name)
+	data, err := os.ReadFile(filepath.Join(out, "d", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "abc" {
+		t.Errorf("zawartość pliku %q, oczekiwane %q", data, "abc")
+	}
+	fa, err := os.Stat(filepath.Join(out, "d", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fa.Mode().Perm() != 0640 {
+		t.Errorf("prawa dostępu %v, oczekiwane %v", fa.Mode().Perm(), os.FileMode(0640))
+	}
+	fb, err := os.Stat(filepath.Join(out, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(fa, fb) {
+		t.Errorf("hard link nie został odtworzony")
+	}
+	link, err := os.Readlink(filepath.Join(out, "s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "d/a" {
+		t.Errorf("symlink wskazuje na %q, oczekiwane %q", link, "d/a")
+	}
+	if _, err := os.Stat(filepath.Join(dst, importDir)); !os.IsNotExist(err) {
+		t.Errorf("nie usunięty katalog tymczasowy %q", importDir)
+	}
+
+	// metadane są przenoszone przez archiwum, ale nie są plikiem w
+	// drzewie snapshotu
+	m, err := ReadMeta(dst, name)
+	if err != nil {
+		t.Fatalf("ReadMeta: %s", err)
+	}
+	meta.Snapshot = name
+	if !reflect.DeepEqual(m, meta) {
+		t.Errorf("metadane po imporcie %+v, oczekiwane %+v", m, meta)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("katalog snapshotu po imporcie zawiera %d plików, oczekiwane 3 (d, b, s)", len(entries))
+	}
+}
+
+func TestImportSymlinkDir(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	outside := t.TempDir()
+	err := os.Chmod(outside, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(outside, "secret"), []byte("abc"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "2015-02-10T18:07:39"
+	var tests = [][]*tar.Header{
+		// zapis przez symlink do katalogu poza dst
+		{
+			{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: name + "/evil", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+			{Name: name + "/evil/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: name + "/evil/pwned", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+		},
+		// hard link do pliku poza dst przez symlink
+		{
+			{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: name + "/evil", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+			{Name: name + "/secret", Typeflag: tar.TypeLink, Linkname: name + "/evil/secret"},
+		},
+	}
+	for i, hdrs := range tests {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range hdrs {
+			err := tw.WriteHeader(hdr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Size > 0 {
+				tw.Write([]byte("abc"))
+			}
+		}
+		err := tw.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dst := t.TempDir()
+		_, err = Import(dst, &buf, CompressNone, Encryption{})
+		if err == nil {
+			t.Errorf("%d: Import nie zwrócił błędu", i)
+		}
+		if _, err := os.Lstat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+			t.Errorf("%d: plik zapisany poza katalogiem dst: %v", i, err)
+		}
+		fi, err := os.Stat(outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0700 {
+			t.Errorf("%d: zmienione prawa dostępu katalogu poza dst: %v", i, fi.Mode().Perm())
+		}
+		for _, file := range []string{name, importDir} {
+			if _, err := os.Lstat(filepath.Join(dst, file)); !os.IsNotExist(err) {
+				t.Errorf("%d: nie usunięty katalog %q: %v", i, file, err)
+			}
+		}
+	}
+}
+
+func TestImportStaleDir(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	src := t.TempDir()
+	dst := t.TempDir()
+	name := "2015-02-10T18:07:39"
+	err := os.Mkdir(filepath.Join(src, name), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// katalog pozostawiony przez przerwany import
+	err = os.MkdirAll(filepath.Join(dst, importDir, name, "d"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = Export(src, name, &buf, CompressNone, Encryption{})
+	if err != nil {
+		t.Fatalf("Export: %s", err)
+	}
+	_, err = Import(dst, &buf, CompressNone, Encryption{})
+	if err != nil {
+		t.Fatalf("Import: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, name, "d")); !os.IsNotExist(err) {
+		t.Errorf("zaimportowany snapshot zawiera pliki z przerwanego importu: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, importDir)); !os.IsNotExist(err) {
+		t.Errorf("nie usunięty katalog tymczasowy %q", importDir)
+	}
+}
+
+func TestImportSameTime(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+	oldLocal := time.Local
+	time.Local = time.FixedZone("CET", 3600)
+	defer func() { time.Local = oldLocal }()
+
+	src := t.TempDir()
+	dst := t.TempDir()
+	name := "2015-02-10T18:07:39"
+	err := os.Mkdir(filepath.Join(src, name), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ten sam czas w formacie utc
+	err = os.Mkdir(filepath.Join(dst, "2015-02-10T17:07:39Z"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = Export(src, name, &buf, CompressNone, Encryption{})
+	if err != nil {
+		t.Fatalf("Export: %s", err)
+	}
+	_, err = Import(dst, &buf, CompressNone, Encryption{})
+	if err == nil {
+		t.Errorf("Import snapshotu z czasem istniejącego snapshotu nie zwrócił błędu")
+	}
+	if _, err := os.Stat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+		t.Errorf("zaimportowany snapshot z czasem istniejącego snapshotu: %v", err)
+	}
+}
+
+func TestImportIncomplete(t *testing.T) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	src := t.TempDir()
+	dst := t.TempDir()
+	name := "2015-02-10T18:07:39"
+	err := os.Mkdir(filepath.Join(src, name), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeMeta(localTransport{}, src, name, &Meta{Status: StatusRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = Export(src, name, &buf, CompressNone, Encryption{})
+	if err != nil {
+		t.Fatalf("Export: %s", err)
+	}
+	_, err = Import(dst, &buf, CompressNone, Encryption{})
+	if err != nil {
+		t.Fatalf("Import: %s", err)
+	}
+	if !strings.Contains(out.String(), "niekompletny") {
+		t.Errorf("Import niekompletnego snapshotu bez ostrzeżenia: %q", out.String())
+	}
+	complete, err := isComplete(localTransport{}, dst, name, globalNaming())
+	if err != nil || complete {
+		t.Errorf("zaimportowany niekompletny snapshot jest kompletny (%v)", err)
+	}
+}
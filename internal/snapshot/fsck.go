@@ -0,0 +1,476 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Rodzaje problemów znajdowanych przez Fsck.
+const (
+	FsckLast       = "last"       // brak lub błędny symlink 'last'
+	FsckWorkDir    = "workdir"    // pozostawiony katalog roboczy
+	FsckName       = "name"       // nazwa, która nie jest timestampem
+	FsckDuplicate  = "duplicate"  // snapshoty z tym samym czasem
+	FsckFuture     = "future"     // snapshot z czasem w przyszłości
+	FsckNoMeta     = "nometa"     // brak metadanych snapshotu
+	FsckIncomplete = "incomplete" // niekompletny snapshot
+	FsckHardlink   = "hardlink"   // pliki, które powinny być hard linkami
+	FsckPin        = "pin"        // przypięcie nieistniejącego snapshotu
+	FsckMeta       = "meta"       // metadane nieistniejącego snapshotu
+)
+
+// Typ Problem opisuje niespójność znalezioną przez Fsck.
+type Problem struct {
+	Kind     string // rodzaj problemu, np. FsckLast
+	Message  string // opis problemu
+	Repaired bool   // problem został naprawiony
+}
+
+// Fsck sprawdza spójność katalogu dst ze snapshotami i zwraca
+// znalezione problemy. Jeśli repair jest true, to naprawia problemy,
+// które można naprawić bezpiecznie:
+//
+//   - symlink 'last' jest ustawiany na najnowszy kompletny snapshot,
+//   - snapshoty bez metadanych (znacznika zakończenia), które są
+//     uznawane za utworzone przez starsze wersje programu, dostają
+//     metadane ze statusem "complete",
+//   - usuwany jest tymczasowy symlink pozostały po przerwanej zmianie
+//     symlinku 'last',
+//   - usuwane są przypięcia i metadane nieistniejących snapshotów oraz
+//     pliki tymczasowe pozostałe po przerwanym zapisie metadanych,
+//   - identyczne pliki z kolejnych snapshotów, które nie są hard
+//     linkami (np. po skopiowaniu snapshotów bez opcji -H), są
+//     zamieniane na hard linki, jeśli ich zawartość jest taka sama.
+//     Pliki w przypiętych snapshotach nie są zmieniane.
+//
+// Pozostałe problemy (np. katalog roboczy 'snapshot', który może
+// należeć do trwającego snapshotu, snapshot bez metadanych, który nie
+// jest uznawany za utworzony przez starszą wersję programu i może być
+// niedokończony, albo metadane ze statusem "running" snapshotu w
+// module serwera rsync, który może jeszcze trwać) są tylko
+// raportowane.
+func Fsck(dst string, repair bool) ([]*Problem, error) {
+	infos, err := localTransport{}.ReadDir(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []*Problem
+	report := func(kind, format string, args ...interface{}) *Problem {
+		p := &Problem{Kind: kind, Message: fmt.Sprintf(format, args...)}
+		problems = append(problems, p)
+		return p
+	}
+
+	// nazwy plików w dst
+	var names []string
+	for _, fi := range infos {
+		name := fi.Name()
+		switch name {
+		case "last", pinsDir, metaDir:
+			continue
+		case "snapshot", importDir:
+			report(FsckWorkDir, "katalog roboczy %q - niedokończony lub trwający snapshot albo import", name)
+			continue
+		case lastTmp:
+			p := report(FsckLast, "tymczasowy symlink %q pozostały po przerwanej zmianie 'last'", name)
+			if repair {
+				err := os.Remove(filepath.Join(dst, name))
+				if err != nil {
+					p.Message += ": " + err.Error()
+				} else {
+					p.Repaired = true
+				}
+			}
+			continue
+		}
+		if _, err := ParseID(name); err != nil || !fi.IsDir() {
+			report(FsckName, "plik %q nie jest katalogiem snapshotu", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sortNames(names)
+
+	// czasy snapshotów; snapshoty z tym samym czasem i numerem
+	// kolejnym (np. "2015-02-10T18:07:39+01:00" i
+	// "2015-02-10T17:07:39Z") mają nieokreśloną kolejność
+	type key struct {
+		t   int64
+		seq int
+	}
+	now := time.Now()
+	ids := make(map[key][]string)
+	for _, name := range names {
+		id, _ := ParseID(name)
+		k := key{id.Time.UnixNano(), id.Seq}
+		ids[k] = append(ids[k], name)
+		if id.Time.After(now) {
+			report(FsckFuture, "snapshot %q ma czas w przyszłości", name)
+		}
+	}
+	for _, name := range names {
+		id, _ := ParseID(name)
+		if a := ids[key{id.Time.UnixNano(), id.Seq}]; len(a) > 1 && a[0] == name {
+			report(FsckDuplicate, "snapshoty %q mają ten sam czas", a)
+		}
+	}
+
+	// snapshoty bez metadanych utworzone przez starsze wersje
+	// programu - sprawdzane przed naprawą, bo zapisanie metadanych
+	// starszego snapshotu zmienia wynik isLegacy dla nowszych
+	have, err := metaNames(localTransport{}, dst, globalNaming())
+	if err != nil {
+		return nil, err
+	}
+	legacy := make(map[string]bool)
+	for _, name := range names {
+		if containsName(have, name) {
+			continue
+		}
+		legacy[name], err = isLegacy(localTransport{}, dst, name, globalNaming())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// metadane
+	var complete []string
+	for _, name := range names {
+		m, err := ReadMeta(dst, name)
+		switch {
+		case os.IsNotExist(err) && !legacy[name]:
+			report(FsckNoMeta, "snapshot %q nie ma znacznika zakończenia (metadanych) - niedokończony snapshot?", name)
+		case os.IsNotExist(err):
+			p := report(FsckNoMeta, "snapshot %q nie ma znacznika zakończenia (metadanych) - utworzony przez starszą wersję programu", name)
+			if repair {
+				err := writeLegacyMeta(dst, name)
+				if err != nil {
+					p.Message += ": " + err.Error()
+				} else {
+					p.Repaired = true
+					complete = append(complete, name)
+				}
+			}
+		case err != nil:
+			report(FsckIncomplete, "snapshot %q ma błędne metadane: %s", name, err)
+		case m.Status != StatusComplete:
+			report(FsckIncomplete, "snapshot %q jest niekompletny (status: %q)", name, m.Status)
+		default:
+			complete = append(complete, name)
+		}
+	}
+
+	// symlink 'last'
+	var newest string
+	if len(complete) > 0 {
+		newest = complete[len(complete)-1]
+	}
+	var p *Problem
+	link, err := os.Readlink(filepath.Join(dst, "last"))
+	switch {
+	case os.IsNotExist(err):
+		if newest != "" {
+			p = report(FsckLast, "brak symlinku 'last'")
+		}
+	case err != nil:
+		p = report(FsckLast, "'last' nie jest symlinkiem")
+	case newest == "":
+		p = report(FsckLast, "symlink 'last' wskazuje na %q, ale nie ma kompletnych snapshotów", link)
+	case filepath.Base(link) != newest:
+		p = report(FsckLast, "symlink 'last' wskazuje na %q zamiast na najnowszy kompletny snapshot %q", link, newest)
+	}
+	if p != nil && repair && newest != "" {
+		err := setLast(localTransport{}, dst, newest)
+		if err != nil {
+			p.Message += ": " + err.Error()
+		} else {
+			p.Repaired = true
+		}
+	}
+
+	// przypięcia
+	pinned, err := readPinned(localTransport{}, dst)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range pinned {
+		if containsName(names, name) {
+			continue
+		}
+		p := report(FsckPin, "przypięcie nieistniejącego snapshotu %q", name)
+		if repair {
+			err := os.Remove(pinFile(dst, name))
+			if err != nil {
+				p.Message += ": " + err.Error()
+			} else {
+				p.Repaired = true
+			}
+		}
+	}
+
+	// metadane pozostałe po snapshocie przerwanym przed zmianą
+	// nazwy katalogu roboczego albo po usuniętym snapshocie
+	metas, err := localTransport{}.ReadDir(filepath.Join(dst, metaDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range metas {
+		if containsName(names, fi.Name()) {
+			continue
+		}
+		var p *Problem
+		if strings.HasSuffix(fi.Name(), ".tmp") {
+			// plik pozostały po przerwanym writeMeta
+			p = report(FsckMeta, "plik tymczasowy %q pozostały po przerwanym zapisie metadanych", fi.Name())
+		} else if m, err := ReadMeta(dst, fi.Name()); err == nil && m.Status == StatusRunning {
+			// startDaemonSnapshot zapisuje metadane przed
+			// utworzeniem katalogu snapshotu przez rsync
+			report(FsckMeta, "metadane snapshotu %q ze statusem %q - trwający lub przerwany snapshot w module serwera rsync", fi.Name(), m.Status)
+			continue
+		} else {
+			p = report(FsckMeta, "metadane nieistniejącego snapshotu %q", fi.Name())
+		}
+		if repair {
+			err := os.Remove(filepath.Join(dst, metaDir, fi.Name()))
+			if err != nil {
+				p.Message += ": " + err.Error()
+			} else {
+				p.Repaired = true
+			}
+		}
+	}
+
+	// hard linki między kolejnymi snapshotami
+	for i := 1; i < len(names); i++ {
+		links, err := brokenLinks(filepath.Join(dst, names[i-1]), filepath.Join(dst, names[i]))
+		if err != nil {
+			return nil, err
+		}
+		if len(links) == 0 {
+			continue
+		}
+		p := report(FsckHardlink, "%d plików w snapshocie %q nie jest hard linkami do identycznych plików w %q",
+			len(links), names[i], names[i-1])
+		if repair && containsName(pinned, names[i]) {
+			p.Message += ": snapshot jest przypięty - pliki nie zostały zmienione"
+		} else if repair {
+			n, err := relink(filepath.Join(dst, names[i-1]), filepath.Join(dst, names[i]), links)
+			p.Message += fmt.Sprintf(": zamienione na hard linki: %d", n)
+			if err != nil {
+				p.Message += ": " + err.Error()
+			} else {
+				p.Repaired = true
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// writeLegacyMeta zapisuje metadane snapshotu name bez metadanych,
+// utworzonego przez starszą wersję programu (legacyMeta).
+func writeLegacyMeta(dst, name string) error {
+	m, err := legacyMeta(filepath.Join(dst, name))
+	if err != nil {
+		return err
+	}
+	return writeMeta(localTransport{}, dst, name, m)
+}
+
+// legacyMeta zwraca metadane ze statusem "complete" dla katalogu
+// snapshotu dir bez metadanych. Czas rozpoczęcia jest czasem z nazwy
+// snapshotu, a czas zakończenia - czasem modyfikacji katalogu.
+func legacyMeta(dir string) (*Meta, error) {
+	id, err := ParseID(filepath.Base(dir))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Meta{
+		Snapshot: id.Name,
+		Status:   StatusComplete,
+		Begin:    id.Time,
+		End:      fi.ModTime(),
+	}, nil
+}
+
+// Typ fileKey zawiera atrybuty pliku porównywane przez rsync
+// --link-dest przy decyzji, czy plik jest hard linkiem do pliku z
+// poprzedniego snapshotu.
+type fileKey struct {
+	size  int64
+	mtime time.Time
+	mode  os.FileMode
+	uid   uint32
+	gid   uint32
+}
+
+// brokenLinks zwraca nazwy (względem cur) zwykłych plików z katalogu
+// cur, które mają takie same atrybuty (także rozszerzone) jak pliki o
+// tych samych nazwach w katalogu prev, ale nie są do nich hard
+// linkami. rsync --link-dest utworzyłby dla nich hard linki. Pomijane
+// są pliki z cur, które mają inne hard linki (np. wewnątrz snapshotu),
+// bo zamiana rozdzieliłaby je.
+func brokenLinks(prev, cur string) ([]string, error) {
+	keys := make(map[string]fileKey)
+	inodes := make(map[string]uint64)
+	err := filepath.Walk(prev, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !fi.Mode().IsRegular() || !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(prev, path)
+		if err != nil {
+			return err
+		}
+		keys[rel] = fileKey{fi.Size(), fi.ModTime(), fi.Mode(), st.Uid, st.Gid}
+		inodes[rel] = uint64(st.Ino)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
+	err = filepath.Walk(cur, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !fi.Mode().IsRegular() || !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(cur, path)
+		if err != nil {
+			return err
+		}
+		key, ok := keys[rel]
+		if !ok || uint64(st.Ino) == inodes[rel] || st.Nlink > 1 {
+			return nil
+		}
+		if key != (fileKey{fi.Size(), fi.ModTime(), fi.Mode(), st.Uid, st.Gid}) {
+			return nil
+		}
+		same, err := sameXattrs(filepath.Join(prev, rel), path)
+		if err != nil {
+			return err
+		}
+		if same {
+			links = append(links, rel)
+		}
+		return nil
+	})
+	return links, err
+}
+
+// relink zamienia pliki links z katalogu cur na hard linki do plików o
+// tych samych nazwach z katalogu prev, jeśli mają taką samą zawartość.
+// Czasy modyfikacji katalogów są zachowywane. Zwraca liczbę
+// zamienionych plików.
+func relink(prev, cur string, links []string) (int, error) {
+	n := 0
+	for _, rel := range links {
+		oldfile := filepath.Join(prev, rel)
+		file := filepath.Join(cur, rel)
+		same, err := sameContent(oldfile, file)
+		if err != nil {
+			return n, err
+		}
+		if !same {
+			continue
+		}
+
+		dir := filepath.Dir(file)
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return n, err
+		}
+		tmp := filepath.Join(dir, ".fsck-"+filepath.Base(file))
+		err = os.Link(oldfile, tmp)
+		if err != nil {
+			return n, err
+		}
+		err = os.Rename(tmp, file)
+		if err != nil {
+			os.Remove(tmp)
+			return n, err
+		}
+		err = os.Chtimes(dir, time.Now(), fi.ModTime())
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// sameXattrs sprawdza czy pliki name1 i name2 mają takie same
+// rozszerzone atrybuty (w tym ACL).
+func sameXattrs(name1, name2 string) (bool, error) {
+	a1, err := xattrs(name1)
+	if err != nil {
+		return false, err
+	}
+	a2, err := xattrs(name2)
+	if err != nil {
+		return false, err
+	}
+	if len(a1) != len(a2) {
+		return false, nil
+	}
+	for name, val := range a1 {
+		if v, ok := a2[name]; !ok || v != val {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sameContent sprawdza czy pliki name1 i name2 mają taką samą
+// zawartość.
+func sameContent(name1, name2 string) (bool, error) {
+	f1, err := os.Open(name1)
+	if err != nil {
+		return false, err
+	}
+	defer f1.Close()
+	f2, err := os.Open(name2)
+	if err != nil {
+		return false, err
+	}
+	defer f2.Close()
+
+	b1 := make([]byte, 64*1024)
+	b2 := make([]byte, 64*1024)
+	for {
+		n1, err1 := io.ReadFull(f1, b1)
+		n2, err2 := io.ReadFull(f2, b2)
+		if n1 != n2 || !bytes.Equal(b1[:n1], b2[:n2]) {
+			return false, nil
+		}
+		if err1 == io.EOF || err1 == io.ErrUnexpectedEOF {
+			return err2 == err1, nil
+		}
+		if err1 != nil {
+			return false, err1
+		}
+		if err2 != nil {
+			return false, err2
+		}
+	}
+}
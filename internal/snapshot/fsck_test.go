@@ -0,0 +1,244 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFsck(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	dst := t.TempDir()
+	// names[0] - snapshot starszej wersji programu bez metadanych,
+	// names[3] - snapshot przerwany przed zapisaniem metadanych
+	names := []string{"2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-12T18:07:39", "2015-02-13T08:00:00"}
+	mtime := time.Date(2015, 2, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range names {
+		dir := filepath.Join(dst, name)
+		err := os.Mkdir(dir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// kopia pliku bez hard linku
+		file := filepath.Join(dir, "a")
+		err = os.WriteFile(file, []byte("abc"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(file, mtime, mtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 || i == 2 {
+			err = writeMeta(localTransport{}, dst, name, &Meta{Status: StatusComplete})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err := writeMeta(localTransport{}, dst, names[2], &Meta{Status: "partial"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"snapshot", "tmp", pinsDir} {
+		err := os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.WriteFile(pinFile(dst, "2015-01-01T00:00:00"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(names[2], filepath.Join(dst, "last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// metadane snapshotu przerwanego przed zmianą nazwy katalogu
+	err = writeMeta(localTransport{}, dst, "2015-02-13T18:07:39", &Meta{Status: StatusComplete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// plik pozostały po przerwanym writeMeta
+	tmp := metaPath(dst, names[1]) + ".tmp"
+	err = os.WriteFile(tmp, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := func(problems []*Problem) map[string]bool {
+		m := make(map[string]bool)
+		for _, p := range problems {
+			m[p.Kind] = p.Repaired
+		}
+		return m
+	}
+
+	problems, err := Fsck(dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		FsckWorkDir:    false,
+		FsckName:       false,
+		FsckNoMeta:     false,
+		FsckIncomplete: false,
+		FsckLast:       false,
+		FsckPin:        false,
+		FsckMeta:       false,
+		FsckHardlink:   false,
+	}
+	if got := kinds(problems); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fsck: %v, oczekiwane %v", got, want)
+	}
+
+	problems, err = Fsck(dst, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[FsckLast] = true
+	want[FsckPin] = true
+	want[FsckMeta] = true
+	want[FsckHardlink] = true
+	if got := kinds(problems); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fsck z naprawą: %v, oczekiwane %v", got, want)
+	}
+
+	// po naprawie zostają tylko problemy nienaprawialne
+	problems, err = Fsck(dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]bool{
+		FsckWorkDir:    false,
+		FsckName:       false,
+		FsckNoMeta:     false,
+		FsckIncomplete: false,
+	}
+	if got := kinds(problems); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fsck po naprawie: %v, oczekiwane %v", got, want)
+	}
+	link, err := os.Readlink(filepath.Join(dst, "last"))
+	if err != nil || link != names[1] {
+		t.Errorf("symlink 'last' wskazuje na %q (%v), oczekiwane %q", link, err, names[1])
+	}
+	if _, err := ReadMeta(dst, names[0]); err != nil {
+		t.Errorf("snapshot starszej wersji programu bez metadanych: %s", err)
+	}
+	if _, err := ReadMeta(dst, names[3]); !os.IsNotExist(err) {
+		t.Errorf("metadane przerwanego snapshotu: %v, oczekiwany brak", err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("nie usunięty plik tymczasowy metadanych: %v", err)
+	}
+	fi0, _ := os.Stat(filepath.Join(dst, names[0], "a"))
+	fi1, _ := os.Stat(filepath.Join(dst, names[1], "a"))
+	fi2, _ := os.Stat(filepath.Join(dst, names[2], "a"))
+	if !os.SameFile(fi0, fi1) || !os.SameFile(fi1, fi2) {
+		t.Errorf("pliki nie zostały zamienione na hard linki")
+	}
+}
+
+func TestFsckRelink(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	dst := t.TempDir()
+	names := []string{"2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-12T18:07:39"}
+	mtime := time.Date(2015, 2, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range names {
+		dir := filepath.Join(dst, name)
+		err := os.Mkdir(dir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"a", "c"} {
+			file = filepath.Join(dir, file)
+			err = os.WriteFile(file, []byte("abc"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.Chtimes(file, mtime, mtime)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		// b jest hard linkiem do a wewnątrz snapshotu (rsync -H)
+		err = os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "b"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeMeta(localTransport{}, dst, name, &Meta{Status: StatusComplete})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Symlink(names[2], filepath.Join(dst, "last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(filepath.Join(dst, pinsDir), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(pinFile(dst, names[2]), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// metadane trwającego snapshotu w module serwera rsync
+	running := "2015-02-13T18:07:39"
+	err = writeMeta(localTransport{}, dst, running, &Meta{Status: StatusRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := Fsck(dst, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, fmt.Sprintf("%s %v", p.Kind, p.Repaired))
+	}
+	want := []string{"meta false", "hardlink true", "hardlink false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Fsck z naprawą: %q, oczekiwane %q", got, want)
+	}
+	if len(problems) == 3 && !strings.Contains(problems[2].Message, "przypięty") {
+		t.Errorf("Fsck z naprawą: komunikat %q dla przypiętego snapshotu", problems[2].Message)
+	}
+
+	same := func(name1, name2 string) bool {
+		fi1, err := os.Stat(filepath.Join(dst, name1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi2, err := os.Stat(filepath.Join(dst, name2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return os.SameFile(fi1, fi2)
+	}
+	if !same(filepath.Join(names[1], "c"), filepath.Join(names[0], "c")) {
+		t.Errorf("plik c nie został zamieniony na hard link")
+	}
+	if same(filepath.Join(names[1], "a"), filepath.Join(names[0], "a")) ||
+		!same(filepath.Join(names[1], "a"), filepath.Join(names[1], "b")) {
+		t.Errorf("rozdzielone hard linki wewnątrz snapshotu %q", names[1])
+	}
+	if same(filepath.Join(names[2], "c"), filepath.Join(names[1], "c")) {
+		t.Errorf("zmieniony plik w przypiętym snapshocie %q", names[2])
+	}
+	if _, err := ReadMeta(dst, running); err != nil {
+		t.Errorf("metadane trwającego snapshotu: %s", err)
+	}
+}
@@ -0,0 +1,243 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Typ Version opisuje jedną wersję pliku w snapshotach. Kolejne
+// snapshoty, w których plik ma ten sam i-węzeł (plik nie zmienił się i
+// rsync --link-dest utworzył hard link), są jedną wersją.
+type Version struct {
+	Path    string      // nazwa pliku względem katalogu snapshotu
+	First   string      // pierwszy snapshot z tą wersją pliku
+	Last    string      // ostatni snapshot z tą wersją pliku
+	Count   int         // liczba snapshotów z tą wersją pliku
+	Size    int64       // rozmiar pliku
+	Mode    os.FileMode // typ i prawa dostępu
+	ModTime time.Time   // czas modyfikacji pliku
+	Inode   uint64      // numer i-węzła
+}
+
+// History zwraca wersje plików pasujących do wzorca pattern we
+// wszystkich snapshotach w katalogu dst. Wzorzec jest nazwą względem
+// katalogu snapshotu i może zawierać metaznaki jak w path.Match oraz
+// '**' oznaczające dowolną liczbę katalogów, np. "**/*.ods". Wynik jest
+// posortowany według nazwy pliku, a wersje jednego pliku
+// chronologicznie.
+func History(dst, pattern string) ([]*Version, error) {
+	pattern = path.Clean(strings.TrimPrefix(filepath.ToSlash(pattern), "/"))
+	if pattern == "." || !isLocalPath(pattern) {
+		return nil, fmt.Errorf("błędny wzorzec %q", pattern)
+	}
+	if _, err := path.Match(strings.Replace(pattern, "**", "*", -1), ""); err != nil {
+		return nil, fmt.Errorf("błędny wzorzec %q", pattern)
+	}
+
+	names, err := listSnapshots(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// ostatnia wersja każdego pliku i indeks snapshotu, w którym
+	// plik wystąpił ostatnio
+	type state struct {
+		v     *Version
+		index int
+	}
+	current := make(map[string]*state)
+	var versions []*Version
+
+	for i, name := range names {
+		dir := filepath.Join(dst, name)
+		err := findFiles(dir, pattern, func(rel string, fi os.FileInfo) {
+			var ino uint64
+			if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+				ino = uint64(st.Ino)
+			}
+			s := current[rel]
+			if s != nil && s.index == i-1 && s.v.Inode == ino {
+				s.v.Last = name
+				s.v.Count++
+				s.index = i
+				return
+			}
+			v := &Version{
+				Path:    rel,
+				First:   name,
+				Last:    name,
+				Count:   1,
+				Size:    fi.Size(),
+				Mode:    fi.Mode(),
+				ModTime: fi.ModTime(),
+				Inode:   ino,
+			}
+			versions = append(versions, v)
+			current[rel] = &state{v: v, index: i}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Path < versions[j].Path
+	})
+	return versions, nil
+}
+
+// findFiles wywołuje fn dla każdego pliku w katalogu dir (poza samym
+// dir), którego nazwa względem dir pasuje do wzorca pattern (jak w
+// matchPath).
+func findFiles(dir, pattern string, fn func(rel string, fi os.FileInfo)) error {
+	if !hasMeta(pattern) {
+		fi, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		fn(pattern, fi)
+		return nil
+	}
+
+	// przeglądanie zaczyna się od najdłuższego prefiksu wzorca
+	// bez metaznaków
+	var prefix []string
+	for _, s := range strings.Split(pattern, "/") {
+		if hasMeta(s) {
+			break
+		}
+		prefix = append(prefix, s)
+	}
+	root := filepath.Join(dir, filepath.FromSlash(strings.Join(prefix, "/")))
+	if _, err := os.Lstat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// katalog snapshotu nie jest plikiem z src
+		if rel != "." && matchPath(pattern, rel) {
+			fn(rel, fi)
+		}
+		return nil
+	})
+}
+
+// hasMeta zwraca true jeśli s zawiera metaznaki wzorca.
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+// matchPath sprawdza czy nazwa pliku name pasuje do wzorca pattern.
+// Elementy nazwy oddzielone znakiem '/' są porównywane z elementami
+// wzorca przez path.Match; element wzorca '**' pasuje do dowolnej
+// liczby (także zera) elementów nazwy.
+func matchPath(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// CopyVersion zapisuje do w zawartość pliku rel ze snapshotu name w
+// katalogu dst.
+func CopyVersion(w io.Writer, dst, name, rel string) error {
+	f, err := openVersion(dst, name, rel)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// RestoreVersion odtwarza plik rel ze snapshotu name w katalogu dst do
+// pliku target z zachowaniem praw dostępu i czasu modyfikacji. Nie
+// nadpisuje istniejącego pliku target.
+func RestoreVersion(dst, name, rel, target string) error {
+	f, err := openVersion(dst, name, rel)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	info("odtworzenie %q ze snapshotu %q do %q", rel, name, target)
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, f)
+	if err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(target, time.Now(), fi.ModTime())
+}
+
+// openVersion otwiera plik rel ze snapshotu name w katalogu dst.
+// Zwraca błąd jeśli plik nie jest zwykłym plikiem.
+func openVersion(dst, name, rel string) (*os.File, error) {
+	if _, err := ParseID(name); err != nil {
+		return nil, err
+	}
+	rel = path.Clean(strings.TrimPrefix(filepath.ToSlash(rel), "/"))
+	if !isLocalPath(rel) {
+		return nil, fmt.Errorf("niedozwolona nazwa pliku %q", rel)
+	}
+	file := filepath.Join(dst, name, filepath.FromSlash(rel))
+	fi, err := os.Lstat(file)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%q nie jest zwykłym plikiem", file)
+	}
+	return os.Open(file)
+}
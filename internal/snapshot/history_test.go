@@ -0,0 +1,163 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchPath(t *testing.T) {
+	var tests = []struct {
+		pattern string // wzorzec
+		name    string // nazwa pliku
+		match   bool   // czy nazwa powinna pasować do wzorca
+	}{
+		{"notes/todo.org", "notes/todo.org", true},
+		{"notes/todo.org", "notes/todo.txt", false},
+		{"notes/*.org", "notes/todo.org", true},
+		{"notes/*.org", "notes/a/todo.org", false},
+		{"**/*.ods", "a.ods", true},
+		{"**/*.ods", "a/b/c/d.ods", true},
+		{"**/*.ods", "a/b/c/d.odt", false},
+		{"home/**/todo.org", "home/todo.org", true},
+		{"home/**/todo.org", "home/adbr/notes/todo.org", true},
+		{"home/**/todo.org", "var/adbr/todo.org", false},
+		{"home/**", "home/adbr/x", true},
+		{"**", "a/b", true},
+		{"a/?", "a/b", true},
+		{"a/[bc]", "a/d", false},
+	}
+
+	for _, test := range tests {
+		m := matchPath(test.pattern, test.name)
+		if m != test.match {
+			t.Errorf("matchPath(%q, %q) = %v, oczekiwane %v", test.pattern, test.name, m, test.match)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	dst := t.TempDir()
+	names := []string{"2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-12T18:07:39"}
+	for _, name := range names {
+		err := os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeMeta(localTransport{}, dst, name, &Meta{Status: StatusComplete})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := func(name, rel, data string) {
+		err := os.WriteFile(filepath.Join(dst, name, rel), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(from, to, rel string) {
+		err := os.Link(filepath.Join(dst, from, rel), filepath.Join(dst, to, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a: niezmieniony w dwóch snapshotach, potem zmieniony
+	file(names[0], "a", "a1")
+	link(names[0], names[1], "a")
+	file(names[2], "a", "a2")
+	// b: zmieniony w drugim snapshocie
+	file(names[0], "b", "b1")
+	file(names[1], "b", "b2")
+	link(names[1], names[2], "b")
+	// c: brak w drugim snapshocie
+	file(names[0], "c", "c1")
+	link(names[0], names[2], "c")
+	mtime := time.Date(2015, 2, 1, 12, 0, 0, 0, time.UTC)
+	err := os.Chtimes(filepath.Join(dst, names[0], "a"), mtime, mtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(names[2], filepath.Join(dst, "last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type version struct {
+		path, first, last string
+		count             int
+	}
+	var tests = []struct {
+		pattern  string
+		versions []version
+	}{
+		{"a", []version{{"a", names[0], names[1], 2}, {"a", names[2], names[2], 1}}},
+		{"/b", []version{{"b", names[0], names[0], 1}, {"b", names[1], names[2], 2}}},
+		{"c", []version{{"c", names[0], names[0], 1}, {"c", names[2], names[2], 1}}},
+		// '**' nie pasuje do katalogu snapshotu ani do metadanych
+		{"**", []version{
+			{"a", names[0], names[1], 2}, {"a", names[2], names[2], 1},
+			{"b", names[0], names[0], 1}, {"b", names[1], names[2], 2},
+			{"c", names[0], names[0], 1}, {"c", names[2], names[2], 1},
+		}},
+		{"x", nil},
+	}
+	for _, test := range tests {
+		versions, err := History(dst, test.pattern)
+		if err != nil {
+			t.Fatalf("History(%q): %s", test.pattern, err)
+		}
+		var got []version
+		for _, v := range versions {
+			got = append(got, version{v.Path, v.First, v.Last, v.Count})
+		}
+		if len(got) != len(test.versions) {
+			t.Errorf("History(%q) = %v, oczekiwane %v", test.pattern, got, test.versions)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.versions[i] {
+				t.Errorf("History(%q) = %v, oczekiwane %v", test.pattern, got, test.versions)
+				break
+			}
+		}
+	}
+	for _, pattern := range []string{"", "/", "../x", "a/[x"} {
+		if _, err := History(dst, pattern); err == nil {
+			t.Errorf("History(%q) nie zwrócił błędu", pattern)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "a")
+	err = RestoreVersion(dst, names[0], "a", target)
+	if err != nil {
+		t.Fatalf("RestoreVersion: %s", err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a1" {
+		t.Errorf("odtworzony plik zawiera %q, oczekiwane %q", data, "a1")
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) || fi.Mode().Perm() != 0644 {
+		t.Errorf("odtworzony plik ma czas %v i prawa %v, oczekiwane %v i %v",
+			fi.ModTime(), fi.Mode().Perm(), mtime, os.FileMode(0644))
+	}
+	if err := RestoreVersion(dst, names[2], "a", target); err == nil {
+		t.Errorf("RestoreVersion nadpisał istniejący plik")
+	}
+	if err := RestoreVersion(dst, names[1], "../"+names[0]+"/a", target+"2"); err == nil {
+		t.Errorf("RestoreVersion odtworzył plik spoza snapshotu")
+	}
+}
@@ -0,0 +1,253 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Zmienne określające nazwy nowych snapshotów. NameLayout jest
+// formatem czasu (jak w time.Format), np. "2006-01-02T15:04:05.000"
+// dla nazw z milisekundami albo "2006-01-02T15:04:05Z07:00" dla nazw z
+// przesunięciem strefy czasowej. Jeśli NameUTC jest true, to czas w
+// nazwie jest czasem UTC, a nie lokalnym.
+var (
+	NameLayout = timestampLayout
+	NameUTC    = false
+)
+
+// Stała timestampLayout jest domyślnym formatem nazw katalogów ze
+// snapshotami (czas lokalny z dokładnością do sekundy).
+const timestampLayout = "2006-01-02T15:04:05"
+
+// Stała seqSeparator oddziela od czasu numer kolejny w nazwie
+// snapshotu, np. "2015-02-10T18:07:39_1". Numer jest dodawany, gdy
+// snapshot o nazwie z samym czasem już istnieje.
+const seqSeparator = "_"
+
+// knownLayouts zawiera formaty nazw snapshotów rozpoznawane niezależnie
+// od NameLayout. Część ułamkowa sekund jest rozpoznawana przez
+// time.Parse także wtedy, gdy nie ma jej w formacie.
+var knownLayouts = []string{
+	timestampLayout,
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+}
+
+// Typ ID jest identyfikatorem snapshotu sparsowanym z nazwy katalogu
+// snapshotu. Identyfikatory są uporządkowane według czasu, a dla tego
+// samego czasu według numeru kolejnego.
+type ID struct {
+	Name string    // nazwa katalogu snapshotu
+	Time time.Time // czas utworzenia snapshotu
+	Seq  int       // numer kolejny snapshotu z tym samym czasem
+}
+
+// ParseID parsuje nazwę katalogu ze snapshotem. Nazwa jest czasem w
+// formacie NameLayout lub jednym z formatów knownLayouts, z opcjonalnym
+// numerem kolejnym po seqSeparator. Czas bez strefy czasowej jest
+// czasem lokalnym (lub UTC, jeśli NameUTC jest true i format jest
+// NameLayout).
+func ParseID(name string) (ID, error) {
+	return parseID(name, NameLayout, NameUTC)
+}
+
+// ParseIDLayout parsuje nazwę snapshotu name tak jak ParseID, ale z
+// formatem layout i czasem UTC (jeśli utc jest true) zamiast NameLayout
+// i NameUTC - np. dla snapshotów zadania z własną opcją -naming (patrz
+// ParseNaming).
+func ParseIDLayout(name, layout string, utc bool) (ID, error) {
+	return parseID(name, layout, utc)
+}
+
+// parseID parsuje nazwę snapshotu name tak jak ParseID, ale z formatem
+// layout i czasem UTC (jeśli utc jest true) zamiast NameLayout i
+// NameUTC.
+func parseID(name, layout string, utc bool) (ID, error) {
+	if strings.Contains(name, "/") {
+		return ID{}, fmt.Errorf("błędna nazwa snapshotu %q", name)
+	}
+	if t, ok := parseTime(name, layout, utc); ok {
+		return ID{Name: name, Time: t}, nil
+	}
+	// nazwa z numerem kolejnym
+	if i := strings.LastIndex(name, seqSeparator); i >= 0 {
+		n, err := strconv.Atoi(name[i+1:])
+		if err == nil && n > 0 && name[i+1] != '+' {
+			if t, ok := parseTime(name[:i], layout, utc); ok {
+				return ID{Name: name, Time: t, Seq: n}, nil
+			}
+		}
+	}
+	return ID{}, fmt.Errorf("błędna nazwa snapshotu %q", name)
+}
+
+// parseTime parsuje czas s w formacie layout (z czasem UTC, jeśli utc
+// jest true) lub jednym z formatów knownLayouts.
+func parseTime(s, layout string, utc bool) (time.Time, bool) {
+	loc := time.Local
+	if utc {
+		loc = time.UTC
+	}
+	if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+		return t, true
+	}
+	for _, known := range knownLayouts {
+		if t, err := time.ParseInLocation(known, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Before zwraca true jeśli snapshot id jest starszy od other.
+func (id ID) Before(other ID) bool {
+	if !id.Time.Equal(other.Time) {
+		return id.Time.Before(other.Time)
+	}
+	if id.Seq != other.Seq {
+		return id.Seq < other.Seq
+	}
+	return id.Name < other.Name
+}
+
+func (id ID) String() string {
+	return id.Name
+}
+
+// formatName zwraca nazwę snapshotu utworzonego w czasie t z numerem
+// kolejnym seq (0 - bez numeru) w formacie layout (z czasem UTC, jeśli
+// utc jest true).
+func formatName(t time.Time, seq int, layout string, utc bool) string {
+	if utc {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+	name := t.Format(layout)
+	if seq > 0 {
+		name += seqSeparator + strconv.Itoa(seq)
+	}
+	return name
+}
+
+// CheckNameLayout sprawdza czy nazwy snapshotów utworzone według
+// formatu layout (z czasem UTC, jeśli utc jest true) mogą być
+// sparsowane przez ParseID.
+func CheckNameLayout(layout string, utc bool) error {
+	t := time.Date(2015, 2, 10, 18, 7, 39, 0, time.UTC)
+	name := formatName(t, 0, layout, utc)
+	id, err := parseID(name, layout, utc)
+	if err != nil {
+		return fmt.Errorf("format nazwy %q: %s", layout, err)
+	}
+	if !id.Time.Equal(t) {
+		return fmt.Errorf("format nazwy %q nie zawiera pełnej daty i czasu", layout)
+	}
+	return nil
+}
+
+// SetNaming ustawia NameLayout i NameUTC według specyfikacji spec
+// (patrz ParseNaming).
+func SetNaming(spec string) error {
+	layout, utc, err := ParseNaming(spec)
+	if err != nil {
+		return err
+	}
+	NameLayout, NameUTC = layout, utc
+	return nil
+}
+
+// ParseNaming zwraca format nazw snapshotów i informację, czy czas w
+// nazwie jest czasem UTC, dla specyfikacji spec, która jest nazwą
+// jednego z formatów:
+//
+//	local	czas lokalny bez strefy czasowej, np. "2015-02-10T18:07:39"
+//		(domyślny)
+//	utc	czas UTC, np. "2015-02-10T17:07:39Z"
+//	offset	czas lokalny z przesunięciem strefy czasowej, np.
+//		"2015-02-10T18:07:39+01:00"
+//
+// albo własnym formatem czasu (jak w time.Format), np.
+// "2006-01-02T15:04:05.000", z opcjonalnym prefiksem "utc:" dla czasu
+// UTC.
+func ParseNaming(spec string) (layout string, utc bool, err error) {
+	layout = spec
+	switch spec {
+	case "local":
+		layout = timestampLayout
+	case "utc":
+		layout, utc = "2006-01-02T15:04:05Z07:00", true
+	case "offset":
+		layout = "2006-01-02T15:04:05Z07:00"
+	default:
+		if strings.HasPrefix(spec, "utc:") {
+			layout, utc = strings.TrimPrefix(spec, "utc:"), true
+		}
+	}
+	err = CheckNameLayout(layout, utc)
+	if err != nil {
+		return "", false, err
+	}
+	return layout, utc, nil
+}
+
+// Typ naming jest formatem nazw snapshotów: formatem czasu layout
+// (jak NameLayout) z czasem UTC, jeśli utc jest true. Pozwala odczytać
+// snapshoty w formacie innym niż NameLayout i NameUTC, np. snapshoty
+// zadania programu backup z własną opcją -naming.
+type naming struct {
+	layout string
+	utc    bool
+}
+
+// globalNaming zwraca format nazw snapshotów NameLayout i NameUTC.
+func globalNaming() naming {
+	return naming{NameLayout, NameUTC}
+}
+
+// parseID parsuje nazwę snapshotu name tak jak ParseID, ale w formacie
+// n.
+func (n naming) parseID(name string) (ID, error) {
+	return parseID(name, n.layout, n.utc)
+}
+
+// sortNames sortuje chronologicznie (według ParseID) nazwy snapshotów
+// names. Nazwy, które nie są nazwami snapshotów, są na końcu.
+func sortNames(names []string) {
+	globalNaming().sortNames(names)
+}
+
+// sortNames działa tak jak funkcja sortNames dla nazw w formacie n.
+func (n naming) sortNames(names []string) {
+	ids := make(map[string]ID, len(names))
+	valid := make(map[string]bool, len(names))
+	for _, name := range names {
+		id, err := n.parseID(name)
+		ids[name], valid[name] = id, err == nil
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := names[i], names[j]
+		if valid[a] != valid[b] {
+			return valid[a]
+		}
+		if !valid[a] {
+			return a < b
+		}
+		return ids[a].Before(ids[b])
+	})
+}
+
+// containsName zwraca true jeśli names zawiera nazwę name.
+func containsName(names []string, name string) bool {
+	for _, s := range names {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseID(t *testing.T) {
+	local := time.Date(2015, 2, 10, 18, 7, 39, 0, time.Local)
+	utc := time.Date(2015, 2, 10, 17, 7, 39, 0, time.UTC)
+	tests := []struct {
+		name string
+		time time.Time
+		seq  int
+		ok   bool
+	}{
+		{"2015-02-10T18:07:39", local, 0, true},
+		{"2015-02-10T18:07:39_2", local, 2, true},
+		{"2015-02-10T18:07:39.250", local.Add(250 * time.Millisecond), 0, true},
+		{"2015-02-10T17:07:39Z", utc, 0, true},
+		{"2015-02-10T18:07:39+01:00", utc, 0, true},
+		{"2015-02-10T17:07:39Z_1", utc, 1, true},
+		{"2015-02-10T18:07:39_0", time.Time{}, 0, false},
+		{"2015-02-10T18:07:39_x", time.Time{}, 0, false},
+		{"2015-02-10", time.Time{}, 0, false},
+		{"snapshot", time.Time{}, 0, false},
+		{"last", time.Time{}, 0, false},
+	}
+	for _, test := range tests {
+		id, err := ParseID(test.name)
+		if (err == nil) != test.ok {
+			t.Errorf("ParseID(%q): błąd %v, oczekiwany sukces: %v", test.name, err, test.ok)
+			continue
+		}
+		if !test.ok {
+			continue
+		}
+		if !id.Time.Equal(test.time) || id.Seq != test.seq {
+			t.Errorf("ParseID(%q): %v %d, oczekiwane %v %d", test.name, id.Time, id.Seq, test.time, test.seq)
+		}
+	}
+}
+
+func TestSortNames(t *testing.T) {
+	loc := time.Local
+	time.Local = time.FixedZone("CET", 3600)
+	defer func() { time.Local = loc }()
+
+	names := []string{
+		"2015-02-10T18:07:39_1",
+		"tmp",
+		"2015-02-10T17:30:00Z",
+		"2015-02-10T18:07:39",
+		"2015-02-11T08:00:00",
+		"2015-02-10T18:07:39.500",
+		"2015-02-10T09:00:00",
+	}
+	sortNames(names)
+	want := []string{
+		"2015-02-10T09:00:00",
+		"2015-02-10T18:07:39",
+		"2015-02-10T18:07:39_1",
+		"2015-02-10T18:07:39.500",
+		"2015-02-10T17:30:00Z",
+		"2015-02-11T08:00:00",
+		"tmp",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sortNames: %q, oczekiwane %q", names, want)
+	}
+}
+
+func TestSetNaming(t *testing.T) {
+	defer SetNaming("local")
+
+	tm := time.Date(2015, 2, 10, 17, 7, 39, 250e6, time.UTC)
+	tests := []struct {
+		spec string
+		name string
+	}{
+		{"utc", "2015-02-10T17:07:39Z"},
+		{"utc:2006-01-02T15:04:05.000", "2015-02-10T17:07:39.250"},
+		{"utc:20060102-150405", "20150210-170739"},
+	}
+	for _, test := range tests {
+		err := SetNaming(test.spec)
+		if err != nil {
+			t.Errorf("SetNaming(%q): %s", test.spec, err)
+			continue
+		}
+		name := formatName(tm, 0, NameLayout, NameUTC)
+		if name != test.name {
+			t.Errorf("SetNaming(%q): nazwa %q, oczekiwana %q", test.spec, name, test.name)
+		}
+		id, err := ParseID(name)
+		if err != nil {
+			t.Errorf("SetNaming(%q): %s", test.spec, err)
+			continue
+		}
+		if !id.Time.Equal(tm.Truncate(time.Second)) && !id.Time.Equal(tm) {
+			t.Errorf("SetNaming(%q): czas %v, oczekiwany %v", test.spec, id.Time, tm)
+		}
+	}
+
+	for _, spec := range []string{"2006-01-02", "15:04:05", "2006/01/02T15:04:05"} {
+		if err := SetNaming(spec); err == nil {
+			t.Errorf("SetNaming(%q): brak błędu", spec)
+		}
+	}
+	// błędny format nie zmienia NameLayout i NameUTC
+	if NameLayout != "20060102-150405" || !NameUTC {
+		t.Errorf("po błędach SetNaming: NameLayout %q, NameUTC %v", NameLayout, NameUTC)
+	}
+	if err := CheckNameLayout(timestampLayout, false); err != nil {
+		t.Errorf("CheckNameLayout(%q): %s", timestampLayout, err)
+	}
+	if NameLayout != "20060102-150405" || !NameUTC {
+		t.Errorf("po CheckNameLayout: NameLayout %q, NameUTC %v", NameLayout, NameUTC)
+	}
+}
+
+func TestSnapshotName(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	dst := t.TempDir()
+	tm := time.Date(2015, 2, 10, 18, 7, 39, 0, time.Local)
+	// snapshot z tym samym czasem w innym formacie nazwy
+	err := os.Mkdir(filepath.Join(dst, tm.UTC().Format("2006-01-02T15:04:05Z07:00")+"_1"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"2015-02-10T18:07:39", "2015-02-10T18:07:39_2", "2015-02-10T18:07:39_3"} {
+		name, err := snapshotName(localTransport{}, dst, tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != want {
+			t.Errorf("snapshotName %d: %q, oczekiwane %q", i, name, want)
+		}
+		err = os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Stała importDir jest nazwą tymczasowego katalogu w katalogu dst, do
+// którego jest rozpakowywany importowany snapshot.
+const importDir = "import"
+
+// Import wczytuje z r archiwum tar utworzone przez Export (rozszyfrowując
+// je zgodnie z enc i dekompresując zgodnie z compress) i odtwarza z
+// niego snapshot w katalogu dst. Zwraca nazwę odtworzonego snapshotu.
+// Archiwum jest rozpakowywane do katalogu tymczasowego i snapshot
+// pojawia się w dst dopiero wtedy, gdy całe archiwum zostało
+// przeczytane i polecenia rozszyfrowujące i dekompresujące zakończyły
+// się bez błędu - age(1) i gpg(1) sprawdzają przy tym integralność
+// danych. Snapshot jest zapisywany na dysk ze znacznikiem zakończenia
+// tak jak snapshot utworzony przez Snapshot. Metadane z archiwum
+// niekompletnego snapshotu są zachowywane (z ostrzeżeniem), więc
+// odtworzony snapshot też nie jest uznawany za kompletny. Import
+// kończy się błędem, jeśli w dst jest już snapshot z tym samym czasem
+// i numerem kolejnym, także w innym formacie nazwy.
+func Import(dst string, r io.Reader, compress string, enc Encryption) (string, error) {
+	dr, err := decryptReader(r, enc)
+	if err != nil {
+		return "", err
+	}
+	cr, err := decompressReader(dr, compress)
+	if err != nil {
+		dr.Close()
+		return "", err
+	}
+
+	tmpdir, err := makeImportDir(dst)
+	if err != nil {
+		cr.Close()
+		dr.Close()
+		return "", err
+	}
+
+	name, meta, err := readTar(cr, tmpdir)
+	if err == nil {
+		// przeczytanie reszty danych, żeby sprawdzić sumy
+		// kontrolne kompresji i szyfrowania
+		_, err = io.Copy(io.Discard, cr)
+	}
+	// błąd rozszyfrowania lub dekompresji jest przyczyną
+	// ewentualnego błędu czytania archiwum, więc jest ważniejszy
+	if cerr := cr.Close(); cerr != nil {
+		err = cerr
+	}
+	if derr := dr.Close(); derr != nil {
+		err = derr
+	}
+	var m *Meta
+	if err == nil {
+		if meta != nil {
+			m, err = parseMeta(paxMeta, name, meta)
+		} else {
+			// archiwum snapshotu bez metadanych (utworzone ze
+			// starszego snapshotu) zostało przeczytane w
+			// całości, więc snapshot jest kompletny
+			m, err = legacyMeta(filepath.Join(tmpdir, name))
+		}
+	}
+	if err == nil && m.Status != StatusComplete {
+		warning("snapshot %q w archiwum jest niekompletny (status: %q) - nie będzie uznawany za kompletny", name, m.Status)
+	}
+	if err == nil {
+		err = finalizeSnapshot(localTransport{}, dst, filepath.Join(tmpdir, name), name, m)
+	}
+	if err != nil {
+		info("usunięcie katalogu %q", tmpdir)
+		os.RemoveAll(tmpdir)
+		return "", err
+	}
+	return name, os.Remove(tmpdir)
+}
+
+// makeImportDir tworzy w katalogu dst katalog tymczasowy importDir.
+// Katalog pozostawiony przez przerwany import zawiera niekompletny
+// snapshot, więc jest usuwany.
+func makeImportDir(dst string) (string, error) {
+	dir := filepath.Join(dst, importDir)
+	err := os.Mkdir(dir, 0700)
+	if os.IsExist(err) {
+		warning("katalog %q już istnieje - nie dokończony poprzedni import?", importDir)
+		info("usunięcie katalogu %q", dir)
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return "", fmt.Errorf("nie można usunąć katalogu %q pozostawionego przez poprzedni import: %s", dir, err)
+		}
+		err = os.Mkdir(dir, 0700)
+	}
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// readTar rozpakowuje archiwum tar z r do katalogu dir. Wszystkie
+// pliki w archiwum muszą być w jednym katalogu o nazwie snapshotu;
+// zwraca tę nazwę i metadane snapshotu z rekordu PAX paxMeta katalogu
+// snapshotu (nil, jeśli ich nie ma).
+func readTar(r io.Reader, dir string) (string, []byte, error) {
+	tr := tar.NewReader(r)
+
+	// prawa dostępu i czasy katalogów są ustawiane na końcu, bo
+	// tworzenie plików w katalogu je zmienia
+	type dirAttrs struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dirAttrs
+
+	// katalogi utworzone z archiwum - plik może być rozpakowany
+	// tylko do takiego katalogu, a nie np. przez symlink z
+	// archiwum; katalog jest dodawany tylko wtedy, gdy na dysku
+	// jest katalogiem, a nie symlinkiem o tej samej nazwie
+	created := map[string]bool{dir: true}
+
+	var snapshot string
+	var meta []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		name := path.Clean(hdr.Name)
+		top := strings.SplitN(name, "/", 2)[0]
+		if snapshot == "" {
+			id, err := ParseID(top)
+			if err != nil {
+				return "", nil, err
+			}
+			err = checkImportID(filepath.Dir(dir), id)
+			if err != nil {
+				return "", nil, err
+			}
+			snapshot = top
+			info("import snapshotu %q", snapshot)
+		}
+		if top != snapshot || !isLocalPath(name) {
+			return "", nil, fmt.Errorf("niedozwolona nazwa pliku w archiwum %q", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if !created[filepath.Dir(target)] {
+			return "", nil, fmt.Errorf("brak katalogu dla pliku z archiwum %q", hdr.Name)
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.Mkdir(target, 0700)
+			if os.IsExist(err) {
+				// powtórzony katalog albo plik o tej samej
+				// nazwie
+				fi, lerr := os.Lstat(target)
+				if lerr != nil {
+					return "", nil, lerr
+				}
+				if !fi.IsDir() {
+					return "", nil, fmt.Errorf("katalog z archiwum %q już istnieje jako plik innego typu", hdr.Name)
+				}
+				err = nil
+			}
+			if err != nil {
+				return "", nil, err
+			}
+			created[target] = true
+			dirs = append(dirs, dirAttrs{target, hdr})
+			if v, ok := hdr.PAXRecords[paxMeta]; ok && name == snapshot {
+				meta = []byte(v)
+			}
+			continue
+		case tar.TypeReg:
+			err = writeFile(target, tr, mode)
+		case tar.TypeLink:
+			linkname := path.Clean(hdr.Linkname)
+			oldname := filepath.Join(dir, filepath.FromSlash(linkname))
+			if !isLocalPath(linkname) || strings.SplitN(linkname, "/", 2)[0] != snapshot ||
+				!created[filepath.Dir(oldname)] {
+				return "", nil, fmt.Errorf("niedozwolony hard link w archiwum %q", hdr.Linkname)
+			}
+			err = os.Link(oldname, target)
+		case tar.TypeSymlink:
+			err = os.Symlink(hdr.Linkname, target)
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			m := uint32(mode)
+			switch hdr.Typeflag {
+			case tar.TypeChar:
+				m |= syscall.S_IFCHR
+			case tar.TypeBlock:
+				m |= syscall.S_IFBLK
+			case tar.TypeFifo:
+				m |= syscall.S_IFIFO
+			}
+			err = syscall.Mknod(target, m, mkdev(hdr.Devmajor, hdr.Devminor))
+		default:
+			warning("pominięcie pliku %q nieobsługiwanego typu %q", hdr.Name, hdr.Typeflag)
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			continue
+		}
+		err = setAttrs(target, hdr)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	if snapshot == "" {
+		return "", nil, fmt.Errorf("puste archiwum")
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err := setAttrs(dirs[i].path, dirs[i].hdr)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return snapshot, meta, nil
+}
+
+// checkImportID zwraca błąd, jeśli w katalogu dst jest już plik o
+// nazwie importowanego snapshotu id albo snapshot z tym samym czasem i
+// numerem kolejnym w innym formacie nazwy (np. "2015-02-10T18:07:39"
+// i "2015-02-10T17:07:39Z" w strefie CET), bo kolejność takich
+// snapshotów jest nieokreślona.
+func checkImportID(dst string, id ID) error {
+	if _, err := os.Lstat(filepath.Join(dst, id.Name)); err == nil {
+		return fmt.Errorf("snapshot %q już istnieje", id.Name)
+	}
+	names, err := readSnapshots(localTransport{}, dst, globalNaming())
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		other, _ := ParseID(name)
+		if other.Time.Equal(id.Time) && other.Seq == id.Seq {
+			return fmt.Errorf("snapshot %q ma ten sam czas co istniejący snapshot %q", id.Name, name)
+		}
+	}
+	return nil
+}
+
+// isLocalPath zwraca true jeśli oczyszczona (path.Clean) nazwa pliku
+// name z archiwum jest względna i nie wychodzi poza katalog, do
+// którego archiwum jest rozpakowywane.
+func isLocalPath(name string) bool {
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return false
+	}
+	return true
+}
+
+// writeFile tworzy plik name z zawartością czytaną z r.
+func writeFile(name string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// setAttrs ustawia właściciela, prawa dostępu, rozszerzone atrybuty i
+// czas modyfikacji pliku name na podstawie nagłówka hdr. Właściciel
+// jest ustawiany tylko gdy program działa z uprawnieniami roota.
+func setAttrs(name string, hdr *tar.Header) error {
+	if os.Geteuid() == 0 {
+		err := os.Lchown(name, hdr.Uid, hdr.Gid)
+		if err != nil {
+			return err
+		}
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+
+	attrs := make(map[string]string)
+	for k, v := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "SCHILY.xattr.") {
+			attrs[strings.TrimPrefix(k, "SCHILY.xattr.")] = v
+		}
+	}
+	err := setXattrs(name, attrs)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(name, unixMode(uint32(hdr.Mode)&07777))
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(name, time.Now(), hdr.ModTime)
+}
+
+// decompressReader zwraca reader dekompresujący dane czytane z r.
+// Zamknięcie zwróconego readera nie zamyka r.
+func decompressReader(r io.Reader, compress string) (io.ReadCloser, error) {
+	switch compress {
+	case CompressNone, "":
+		return io.NopCloser(r), nil
+	case CompressGzip:
+		return gzip.NewReader(r)
+	case CompressZstd:
+		return commandReader(r, ZstdCommand, "-q", "-d", "-c")
+	}
+	return nil, fmt.Errorf("nieznany rodzaj kompresji %q", compress)
+}
+
+// decryptReader zwraca reader rozszyfrowujący dane czytane z r kluczem
+// prywatnym. Dla age plik z kluczem prywatnym jest w enc.Identity, a
+// gpg używa klucza ze swojego keyringu. Błąd integralności danych jest
+// zwracany przez Close.
+func decryptReader(r io.Reader, enc Encryption) (io.ReadCloser, error) {
+	switch enc.Method {
+	case "":
+		return io.NopCloser(r), nil
+	case EncryptAge:
+		if enc.Identity == "" {
+			return nil, fmt.Errorf("brak pliku z kluczem prywatnym age")
+		}
+		return commandReader(r, AgeCommand, "-d", "-i", enc.Identity)
+	case EncryptGpg:
+		return commandReader(r, GpgCommand, "--batch", "--decrypt")
+	}
+	return nil, fmt.Errorf("nieznany rodzaj szyfrowania %q", enc.Method)
+}
+
+// Typ cmdReader zwraca dane ze stdout polecenia systemowego. Close
+// czeka na zakończenie polecenia i zwraca błąd, jeśli polecenie
+// zakończyło się błędem.
+type cmdReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+}
+
+// commandReader uruchamia polecenie name z argumentami args, którego
+// stdin jest czytany z r, i zwraca reader jego stdout.
+func commandReader(r io.Reader, name string, args ...string) (io.ReadCloser, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = r
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	info("polecenie: %q", strings.Join(cmd.Args, " "))
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &cmdReader{cmd: cmd, stdout: stdout}, nil
+}
+
+func (r *cmdReader) Read(p []byte) (int, error) {
+	return r.stdout.Read(p)
+}
+
+func (r *cmdReader) Close() error {
+	// przeczytanie reszty wyjścia, żeby polecenie mogło się
+	// zakończyć
+	io.Copy(io.Discard, r.stdout)
+	err := r.cmd.Wait()
+	if err != nil {
+		return fmt.Errorf("%s: %s", r.cmd.Args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Stała metaDir jest nazwą katalogu w katalogu docelowym dst, w
+// którym są przechowywane metadane snapshotów - poza drzewem danych
+// snapshotu, żeby nie były traktowane jak pliki z src (np. przez
+// History, Export i rsync). Metadane snapshotu są w pliku o nazwie
+// takiej jak nazwa katalogu snapshotu. Plik ze statusem
+// StatusComplete jest znacznikiem zakończenia snapshotu: jest
+// zapisywany po zakończeniu rsync i zapisaniu danych na dysk, przed
+// zmianą nazwy katalogu roboczego na timestamp (finalizeSnapshot), a
+// w module serwera rsync zastępuje plik ze statusem StatusRunning
+// zapisany przed uruchomieniem rsync (startDaemonSnapshot).
+const metaDir = ".meta"
+
+// Stałe określające stan snapshotu w metadanych.
+const (
+	StatusComplete = "complete"
+	StatusRunning  = "running" // snapshot w module serwera rsync przed zakończeniem rsync
+)
+
+// Typ Meta zawiera metadane snapshotu.
+type Meta struct {
+	Snapshot string    // nazwa snapshotu
+	Status   string    // stan snapshotu, np. StatusComplete
+	Src      string    // backupowany katalog
+	Begin    time.Time // czas rozpoczęcia snapshotu
+	End      time.Time // czas zakończenia rsync
+	Errors   string    // liczba komunikatów błędów rsync
+}
+
+// ReadMeta wczytuje metadane snapshotu name z katalogu dst. Jeśli
+// snapshot nie ma metadanych (bo został utworzony przez starszą wersję
+// programu), zwraca błąd, dla którego os.IsNotExist zwraca true.
+func ReadMeta(dst, name string) (*Meta, error) {
+	return readMeta(localTransport{}, dst, name)
+}
+
+// readMeta działa tak jak ReadMeta dla katalogu dst dostępnego przez
+// Transport t.
+func readMeta(t Transport, dst, name string) (*Meta, error) {
+	file := metaPath(dst, name)
+	data, err := t.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseMeta(file, name, data)
+}
+
+// parseMeta parsuje metadane data snapshotu name wczytane z pliku file.
+func parseMeta(file, name string, data []byte) (*Meta, error) {
+	m := &Meta{Snapshot: name}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		i := strings.Index(line, ": ")
+		if i < 0 {
+			return nil, fmt.Errorf("%s: błędny wiersz %q", file, line)
+		}
+		key, val := line[:i], line[i+2:]
+		switch key {
+		case "status":
+			m.Status = val
+		case "src":
+			m.Src = val
+		case "begin", "end":
+			tm, err := time.Parse(time.RFC3339, val)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err)
+			}
+			if key == "begin" {
+				m.Begin = tm
+			} else {
+				m.End = tm
+			}
+		case "errors":
+			m.Errors = val
+		}
+	}
+	return m, nil
+}
+
+// formatMeta zwraca metadane m w postaci zapisywanej do pliku.
+func formatMeta(m *Meta) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "status: %s\n", m.Status)
+	fmt.Fprintf(&b, "src: %s\n", m.Src)
+	fmt.Fprintf(&b, "begin: %s\n", m.Begin.Format(time.RFC3339))
+	fmt.Fprintf(&b, "end: %s\n", m.End.Format(time.RFC3339))
+	if m.Errors != "" {
+		fmt.Fprintf(&b, "errors: %s\n", m.Errors)
+	}
+	return []byte(b.String())
+}
+
+// writeMeta zapisuje metadane m snapshotu name w katalogu dst. Plik
+// jest zapisywany pod tymczasową nazwą i zastępuje poprzedni plik przez
+// zmianę nazwy, więc nie zostaje niekompletny plik z metadanymi.
+func writeMeta(t Transport, dst, name string, m *Meta) error {
+	err := t.Mkdir(filepath.Join(dst, metaDir))
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	file := metaPath(dst, name)
+	tmp := file + ".tmp"
+	err = t.WriteFile(tmp, formatMeta(m))
+	if err != nil {
+		return err
+	}
+	return t.Replace(tmp, file)
+}
+
+// metaPath zwraca nazwę pliku z metadanymi snapshotu name w katalogu
+// dst.
+func metaPath(dst, name string) string {
+	return filepath.Join(dst, metaDir, name)
+}
+
+// isComplete sprawdza czy snapshot name w katalogu dst ma znacznik
+// zakończenia. Snapshot bez metadanych jest uznawany za kompletny,
+// jeśli został utworzony przez starszą wersję programu (isLegacy).
+// Nazwy snapshotów są w formacie n.
+func isComplete(t Transport, dst, name string, n naming) (bool, error) {
+	m, err := readMeta(t, dst, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return isLegacy(t, dst, name, n)
+		}
+		return false, err
+	}
+	return m.Status == StatusComplete, nil
+}
+
+// isLegacy sprawdza czy snapshot name w katalogu dst bez metadanych
+// został utworzony przez starszą wersję programu, która nie zapisywała
+// metadanych, ale zmieniała nazwę katalogu roboczego na timestamp
+// dopiero po zakończeniu rsync. Tak jest, jeśli wskazuje na niego
+// symlink 'last' albo jest starszy od wszystkich snapshotów z
+// metadanymi. Nowszy snapshot bez metadanych (np. przerwany przed
+// zapisaniem metadanych albo po usunięciu pliku z metadanymi) nie jest
+// uznawany za kompletny. Nazwy snapshotów są w formacie n.
+func isLegacy(t Transport, dst, name string, n naming) (bool, error) {
+	link, err := t.Readlink(filepath.Join(dst, "last"))
+	if err == nil && filepath.Base(link) == name {
+		return true, nil
+	}
+	id, err := n.parseID(name)
+	if err != nil {
+		return false, err
+	}
+	names, err := metaNames(t, dst, n)
+	if err != nil {
+		return false, err
+	}
+	if len(names) == 0 {
+		return true, nil
+	}
+	first, _ := n.parseID(names[0])
+	return id.Before(first), nil
+}
+
+// metaNames zwraca posortowane nazwy snapshotów, które mają plik z
+// metadanymi w katalogu dst, w formacie n.
+func metaNames(t Transport, dst string, n naming) ([]string, error) {
+	infos, err := t.ReadDir(filepath.Join(dst, metaDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, fi := range infos {
+		if _, err := n.parseID(fi.Name()); err == nil {
+			names = append(names, fi.Name())
+		}
+	}
+	n.sortNames(names)
+	return names, nil
+}
+
+// warnNoMeta loguje ostrzeżenie dla każdego snapshotu z listy names w
+// katalogu dst, który nie ma metadanych i nie jest uznawany za
+// kompletny (isLegacy).
+func warnNoMeta(t Transport, dst string, names []string) error {
+	have, err := metaNames(t, dst, globalNaming())
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if containsName(have, name) {
+			continue
+		}
+		ok, err := isLegacy(t, dst, name, globalNaming())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			warning("snapshot %q nie ma metadanych i nie jest uznawany za kompletny; "+
+				"może być niedokończony (patrz 'snapshot fsck')", name)
+		}
+	}
+	return nil
+}
+
+// NewestComplete zwraca metadane najnowszego kompletnego snapshotu w
+// katalogu dst (lokalnym lub zdalnym) albo nil, jeśli w dst nie ma
+// kompletnych snapshotów. Dla snapshotu utworzonego przez starszą
+// wersję programu zwracane metadane zawierają tylko nazwę, status i
+// czas rozpoczęcia (z nazwy snapshotu).
+func NewestComplete(dst string) (*Meta, error) {
+	return NewestCompleteLayout(dst, NameLayout, NameUTC)
+}
+
+// NewestCompleteLayout działa tak jak NewestComplete dla snapshotów z
+// nazwami w formacie layout (z czasem UTC, jeśli utc jest true) zamiast
+// NameLayout i NameUTC - np. snapshotów zadania programu backup z
+// własną opcją -naming.
+func NewestCompleteLayout(dst, layout string, utc bool) (*Meta, error) {
+	n := naming{layout, utc}
+	t, dstdir := newTransport(dst)
+	names, err := readSnapshots(t, dstdir, n)
+	if err != nil {
+		return nil, err
+	}
+	name := newestComplete(t, dstdir, names, n)
+	if name == "" {
+		return nil, nil
+	}
+	m, err := readMeta(t, dstdir, name)
+	if os.IsNotExist(err) {
+		// snapshot utworzony przez starszą wersję programu
+		id, _ := n.parseID(name)
+		return &Meta{Snapshot: name, Status: StatusComplete, Begin: id.Time}, nil
+	}
+	return m, err
+}
+
+// newestComplete zwraca najnowszy kompletny snapshot z posortowanej
+// listy names w katalogu dst lub "" jeśli nie ma takiego snapshotu.
+// Snapshoty z błędnymi metadanymi są pomijane. Nazwy snapshotów są w
+// formacie n.
+func newestComplete(t Transport, dst string, names []string, n naming) string {
+	for i := len(names) - 1; i >= 0; i-- {
+		ok, err := isComplete(t, dst, names[i], n)
+		if err != nil {
+			warning("snapshot %q: %s", names[i], err)
+			continue
+		}
+		if ok {
+			return names[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsComplete(t *testing.T) {
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+
+	dst := t.TempDir()
+	names := []string{
+		"2015-02-10T18:07:39", // starsza wersja programu
+		"2015-02-11T18:07:39", // starsza wersja programu
+		"2015-02-12T18:07:39", // kompletny
+		"2015-02-13T18:07:39", // bez metadanych
+		"2015-02-14T18:07:39", // niekompletny
+		"2015-02-15T18:07:39", // bez metadanych
+	}
+	for _, name := range names {
+		err := os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := writeMeta(localTransport{}, dst, names[2], &Meta{Status: StatusComplete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeMeta(localTransport{}, dst, names[4], &Meta{Status: "partial"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(want []bool) {
+		t.Helper()
+		for i, name := range names {
+			ok, err := isComplete(localTransport{}, dst, name, globalNaming())
+			if err != nil || ok != want[i] {
+				t.Errorf("isComplete(%q) = %v, %v, oczekiwane %v", name, ok, err, want[i])
+			}
+		}
+	}
+	check([]bool{true, true, true, false, false, false})
+	m, err := NewestComplete(dst)
+	if err != nil || m == nil || m.Snapshot != names[2] {
+		t.Errorf("NewestComplete = %v, %v, oczekiwany snapshot %q", m, err, names[2])
+	}
+
+	// snapshot wskazywany przez 'last' jest kompletny
+	err = os.Symlink(names[5], filepath.Join(dst, "last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check([]bool{true, true, true, false, false, true})
+	m, err = NewestComplete(dst)
+	if err != nil || m == nil || m.Snapshot != names[5] || m.Status != StatusComplete {
+		t.Errorf("NewestComplete = %v, %v, oczekiwany snapshot %q", m, err, names[5])
+	}
+
+	// ostrzeżenie tylko dla snapshotu bez metadanych, który nie jest
+	// uznawany za kompletny
+	out.Reset()
+	err = warnNoMeta(localTransport{}, dst, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := out.String()
+	if strings.Count(msg, "snapshot fsck") != 1 || !strings.Contains(msg, names[3]) {
+		t.Errorf("warnNoMeta: %q", msg)
+	}
+}
@@ -0,0 +1,13 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+// mkdev zwraca numer urządzenia dla syscall.Mknod złożony z numerów
+// major i minor (jak makedev(3) w glibc).
+func mkdev(major, minor int64) int {
+	dev := (uint64(major) & 0x00000fff) << 8
+	dev |= (uint64(major) & 0xfffff000) << 32
+	dev |= (uint64(minor) & 0x000000ff) << 0
+	dev |= (uint64(minor) & 0xffffff00) << 12
+	return int(dev)
+}
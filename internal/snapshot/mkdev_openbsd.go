@@ -0,0 +1,9 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+// mkdev zwraca numer urządzenia dla syscall.Mknod złożony z numerów
+// major i minor (jak makedev(3) w OpenBSD).
+func mkdev(major, minor int64) int {
+	return int((major&0xff)<<8 | (minor & 0xff) | (minor&0xffff00)<<8)
+}
@@ -0,0 +1,11 @@
+// 2026-10-18 adbr
+
+//go:build !linux && !openbsd
+
+package snapshot
+
+// mkdev zwraca numer urządzenia dla syscall.Mknod złożony z numerów
+// major i minor (jak makedev(3) w macOS).
+func mkdev(major, minor int64) int {
+	return int((major << 24) | minor)
+}
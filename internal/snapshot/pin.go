@@ -0,0 +1,369 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stała pinsDir jest nazwą katalogu w katalogu docelowym dst, w
+// którym są przechowywane informacje o przypiętych snapshotach. Każdy
+// przypięty snapshot ma w nim plik o nazwie takiej jak nazwa katalogu
+// snapshotu.
+const pinsDir = "pins"
+
+// Typ Pin opisuje przypięty snapshot. Przypiętego snapshotu nie można
+// usunąć, jest też pomijany przez Prune.
+type Pin struct {
+	Snapshot string    // nazwa katalogu snapshotu (timestamp)
+	Reason   string    // powód przypięcia
+	Time     time.Time // czas przypięcia
+	ReadOnly bool      // czy katalogi snapshotu są tylko do odczytu
+
+	// oryginalne prawa dostępu katalogów, którym przy
+	// przypięciu zostało odebrane prawo zapisu; kluczem jest
+	// nazwa katalogu względem katalogu snapshotu
+	modes map[string]os.FileMode
+}
+
+// PinSnapshot przypina snapshot name w katalogu dst. Argument reason
+// jest opisem powodu przypięcia (może być pusty). Jeśli readonly jest
+// true to wszystkim katalogom snapshotu jest odbierane prawo zapisu,
+// żeby przypadkowe 'rm -rf' się nie powiodło. Prawa dostępu plików
+// nie są zmieniane, bo pliki są współdzielone przez hard linki z
+// innymi snapshotami.
+func PinSnapshot(dst, name, reason string, readonly bool) error {
+	if _, err := ParseID(name); err != nil {
+		return err
+	}
+	dir := filepath.Join(dst, name)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%q nie jest katalogiem", dir)
+	}
+
+	pin, err := readPin(dst, name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if pin == nil {
+		pin = &Pin{Snapshot: name}
+	}
+	pin.Reason = reason
+	pin.Time = time.Now()
+
+	if readonly && !pin.ReadOnly {
+		// zapisanie pinu przed zmianą praw dostępu, żeby w
+		// razie błędu można było je przywrócić przez Unpin
+		modes, err := dirModes(dir)
+		if err != nil {
+			return err
+		}
+		pin.ReadOnly = true
+		pin.modes = modes
+		err = writePin(dst, pin)
+		if err != nil {
+			return err
+		}
+		info("odebranie prawa zapisu katalogom snapshotu %q", name)
+		err = setReadOnly(dir, modes)
+		if err != nil {
+			return err
+		}
+		info("przypięcie snapshotu %q", name)
+		return nil
+	}
+
+	info("przypięcie snapshotu %q", name)
+	return writePin(dst, pin)
+}
+
+// Unpin odpina snapshot name w katalogu dst. Jeśli katalogi snapshotu
+// były tylko do odczytu to przywraca ich oryginalne prawa dostępu.
+func Unpin(dst, name string) error {
+	if _, err := ParseID(name); err != nil {
+		return err
+	}
+	pin, err := readPin(dst, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("snapshot %q nie jest przypięty", name)
+		}
+		return err
+	}
+
+	if pin.ReadOnly {
+		info("przywrócenie praw dostępu katalogów snapshotu %q", name)
+		dir := filepath.Join(dst, name)
+		for rel, mode := range pin.modes {
+			err := os.Chmod(filepath.Join(dir, rel), mode)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	info("odpięcie snapshotu %q", name)
+	return os.Remove(pinFile(dst, name))
+}
+
+// Pins zwraca listę przypiętych snapshotów w katalogu dst posortowaną
+// chronologicznie.
+func Pins(dst string) ([]*Pin, error) {
+	names, err := readPinned(localTransport{}, dst)
+	if err != nil {
+		return nil, err
+	}
+	var pins []*Pin
+	for _, name := range names {
+		pin, err := readPin(dst, name)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// readPinned zwraca posortowane nazwy przypiętych snapshotów w katalogu
+// dst dostępnym przez Transport t.
+func readPinned(t Transport, dst string) ([]string, error) {
+	infos, err := t.ReadDir(filepath.Join(dst, pinsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, fi := range infos {
+		if fi.IsDir() {
+			continue
+		}
+		if _, err := ParseID(fi.Name()); err != nil {
+			continue
+		}
+		names = append(names, fi.Name())
+	}
+	sortNames(names)
+	return names, nil
+}
+
+// IsPinned zwraca true jeśli snapshot name w katalogu dst jest
+// przypięty.
+func IsPinned(dst, name string) (bool, error) {
+	_, err := os.Stat(pinFile(dst, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete usuwa snapshot name i jego metadane z katalogu dst. Zwraca
+// błąd jeśli snapshot jest przypięty albo wskazuje na niego symlink
+// 'last'.
+func Delete(dst, name string) error {
+	if _, err := ParseID(name); err != nil {
+		return err
+	}
+	pinned, err := IsPinned(dst, name)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		return fmt.Errorf("snapshot %q jest przypięty", name)
+	}
+	last, err := os.Readlink(filepath.Join(dst, "last"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if filepath.Base(last) == name {
+		return fmt.Errorf("na snapshot %q wskazuje symlink 'last'", name)
+	}
+
+	dir := filepath.Join(dst, name)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%q nie jest katalogiem", dir)
+	}
+	info("usunięcie snapshotu %q", name)
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	// metadane są usuwane po katalogu, żeby przerwanie nie
+	// zostawiło snapshotu bez znacznika zakończenia
+	err = os.Remove(metaPath(dst, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// pinFile zwraca nazwę pliku z informacjami o przypięciu snapshotu
+// name.
+func pinFile(dst, name string) string {
+	return filepath.Join(dst, pinsDir, name)
+}
+
+// readPin wczytuje informacje o przypięciu snapshotu name. Plik ma
+// postać wierszy "klucz: wartość"; wiersze "mode:" zawierają
+// oryginalne prawa dostępu katalogu i jego nazwę (w cudzysłowie).
+func readPin(dst, name string) (*Pin, error) {
+	f, err := os.Open(pinFile(dst, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pin := &Pin{Snapshot: name}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.Index(line, ": ")
+		if i < 0 {
+			return nil, fmt.Errorf("%s: błędny wiersz %q", f.Name(), line)
+		}
+		key, val := line[:i], line[i+2:]
+		switch key {
+		case "reason":
+			pin.Reason = val
+		case "time":
+			t, err := time.Parse(time.RFC3339, val)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", f.Name(), err)
+			}
+			pin.Time = t
+		case "readonly":
+			pin.ReadOnly = val == "yes"
+		case "mode":
+			j := strings.Index(val, " ")
+			if j < 0 {
+				return nil, fmt.Errorf("%s: błędny wiersz %q", f.Name(), line)
+			}
+			n, err := strconv.ParseUint(val[:j], 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", f.Name(), err)
+			}
+			rel, err := strconv.Unquote(val[j+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s: błędna nazwa katalogu w wierszu %q", f.Name(), line)
+			}
+			if pin.modes == nil {
+				pin.modes = make(map[string]os.FileMode)
+			}
+			pin.modes[rel] = unixMode(uint32(n))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pin, nil
+}
+
+// writePin zapisuje informacje o przypięciu snapshotu. Plik jest
+// zapisywany do pliku tymczasowego i przemianowywany, żeby nie
+// zostawić niekompletnego pliku.
+func writePin(dst string, pin *Pin) error {
+	dir := filepath.Join(dst, pinsDir)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "reason: %s\n", strings.Replace(pin.Reason, "\n", " ", -1))
+	fmt.Fprintf(&b, "time: %s\n", pin.Time.Format(time.RFC3339))
+	if pin.ReadOnly {
+		fmt.Fprintf(&b, "readonly: yes\n")
+		for rel, mode := range pin.modes {
+			fmt.Fprintf(&b, "mode: %o %q\n", fileModeBits(mode), rel)
+		}
+	}
+
+	file := pinFile(dst, pin.Snapshot)
+	tmp := file + ".tmp"
+	err = os.WriteFile(tmp, []byte(b.String()), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// dirModes zwraca prawa dostępu katalogów w drzewie dir, które mają
+// prawo zapisu. Kluczem jest nazwa katalogu względem dir.
+func dirModes(dir string) (map[string]os.FileMode, error) {
+	modes := make(map[string]os.FileMode)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() || fi.Mode()&0222 == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		modes[rel] = fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		return nil
+	})
+	return modes, err
+}
+
+// setReadOnly odbiera prawo zapisu katalogom z modes.
+func setReadOnly(dir string, modes map[string]os.FileMode) error {
+	for rel, mode := range modes {
+		err := os.Chmod(filepath.Join(dir, rel), mode&^0222)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileModeBits zamienia os.FileMode na bity praw dostępu w postaci
+// używanej przez chmod(1), np. 01777.
+func fileModeBits(mode os.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return bits
+}
+
+// unixMode jest odwrotnością fileModeBits.
+func unixMode(bits uint32) os.FileMode {
+	mode := os.FileMode(bits & 0777)
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
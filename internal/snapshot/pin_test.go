@@ -0,0 +1,134 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	dst := t.TempDir()
+	names := []string{"2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-12T18:07:39"}
+	for _, name := range names {
+		err := os.MkdirAll(filepath.Join(dst, name, "a", "b"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeMeta(localTransport{}, dst, name, &Meta{Status: StatusComplete})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// katalog bez prawa zapisu przed przypięciem
+	err := os.Mkdir(filepath.Join(dst, names[0], "ro"), 0555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(names[2], filepath.Join(dst, "last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = PinSnapshot(dst, names[0], "przed aktualizacją", true)
+	if err != nil {
+		t.Fatalf("PinSnapshot: %s", err)
+	}
+	for _, rel := range []string{".", "a", "a/b", "ro"} {
+		fi, err := os.Stat(filepath.Join(dst, names[0], rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode()&0222 != 0 {
+			t.Errorf("katalog %q przypiętego snapshotu ma prawa %v", rel, fi.Mode())
+		}
+	}
+	pinned, err := IsPinned(dst, names[0])
+	if err != nil || !pinned {
+		t.Errorf("IsPinned(%q) = %v, %v", names[0], pinned, err)
+	}
+
+	if err := Delete(dst, names[0]); err == nil {
+		t.Errorf("Delete usunął przypięty snapshot")
+	}
+	if err := Delete(dst, names[2]); err == nil {
+		t.Errorf("Delete usunął snapshot wskazywany przez 'last'")
+	}
+	for _, name := range []string{names[0], names[2]} {
+		if _, err := os.Stat(filepath.Join(dst, name)); err != nil {
+			t.Errorf("snapshot %q: %s", name, err)
+		}
+	}
+	for _, name := range []string{"../x", "pins", ""} {
+		if err := Unpin(dst, name); err == nil {
+			t.Errorf("Unpin(%q) nie zwrócił błędu", name)
+		}
+		if err := Delete(dst, name); err == nil {
+			t.Errorf("Delete(%q) nie zwrócił błędu", name)
+		}
+	}
+	if err := Unpin(dst, names[1]); err == nil {
+		t.Errorf("Unpin nieprzypiętego snapshotu nie zwrócił błędu")
+	}
+
+	err = Unpin(dst, names[0])
+	if err != nil {
+		t.Fatalf("Unpin: %s", err)
+	}
+	for rel, want := range map[string]os.FileMode{".": 0755, "a": 0755, "a/b": 0755, "ro": 0555} {
+		fi, err := os.Stat(filepath.Join(dst, names[0], rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != want {
+			t.Errorf("katalog %q po odpięciu ma prawa %v, oczekiwane %v", rel, fi.Mode().Perm(), want)
+		}
+	}
+
+	err = Delete(dst, names[0])
+	if err != nil {
+		t.Fatalf("Delete po odpięciu: %s", err)
+	}
+	for _, file := range []string{filepath.Join(dst, names[0]), metaPath(dst, names[0])} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%q nie został usunięty: %v", file, err)
+		}
+	}
+}
+
+func TestPinsOrder(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	// kolejność chronologiczna różni się od leksykograficznej
+	dst := t.TempDir()
+	names := []string{"2015-02-10T18:07:39", "2015-02-10T18:07:39_2", "2015-02-10T18:07:39_10", "2015-02-11T18:07:39"}
+	for i := len(names) - 1; i >= 0; i-- {
+		err := os.Mkdir(filepath.Join(dst, names[i]), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = PinSnapshot(dst, names[i], "", false)
+		if err != nil {
+			t.Fatalf("PinSnapshot: %s", err)
+		}
+	}
+
+	pins, err := Pins(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pin := range pins {
+		got = append(got, pin.Snapshot)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("Pins: %q, oczekiwane %q", got, names)
+	}
+}
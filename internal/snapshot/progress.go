@@ -0,0 +1,193 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Zmienne określające raportowanie postępu snapshotu. Jeśli Progress
+// jest true, to rsync jest uruchamiany z opcją --info=progress2, a jego
+// rekordy postępu są pokazywane w jednej linii na terminalu (stderr) i
+// co ProgressInterval logowane i zapisywane do pliku StatusFile (jeśli
+// nie jest pusty).
+var (
+	Progress         = false
+	ProgressInterval = 10 * time.Second
+	StatusFile       = ""
+)
+
+// Typ progressRecord jest rekordem postępu wypisywanym przez rsync
+// --info=progress2, np.:
+//
+//	"  1,234,567  12%   10.50MB/s    0:01:23 (xfr#5, to-chk=100/200)"
+type progressRecord struct {
+	Bytes   string // liczba przesłanych bajtów
+	Percent int    // procent wykonania
+	Rate    string // prędkość transferu
+	ETA     string // przewidywany czas do końca
+	Files   int    // liczba przesłanych plików
+	ToCheck int    // liczba plików do sprawdzenia
+	Total   int    // liczba wszystkich plików
+}
+
+var progressRegexp = regexp.MustCompile(
+	`^\s*([0-9][0-9,.]*[KMGT]?)\s+([0-9]+)%\s+(\S+)\s+([0-9]+:[0-9]{2}:[0-9]{2})` +
+		`(?:\s+\(xfr#([0-9]+), (?:to|ir)-chk=([0-9]+)/([0-9]+)\))?\s*$`)
+
+// parseProgress parsuje rekord postępu rsync. Zwraca false jeśli line
+// nie jest rekordem postępu (np. jest nazwą pliku).
+func parseProgress(line string) (progressRecord, bool) {
+	m := progressRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return progressRecord{}, false
+	}
+	r := progressRecord{
+		Bytes: strings.Replace(m[1], ",", "", -1),
+		Rate:  m[3],
+		ETA:   m[4],
+	}
+	r.Percent, _ = strconv.Atoi(m[2])
+	if m[5] != "" {
+		r.Files, _ = strconv.Atoi(m[5])
+		r.ToCheck, _ = strconv.Atoi(m[6])
+		r.Total, _ = strconv.Atoi(m[7])
+	}
+	return r, true
+}
+
+// String zwraca rekord postępu w postaci do pokazania w jednej linii.
+func (r progressRecord) String() string {
+	s := fmt.Sprintf("%3d%% %s B, %s, eta %s", r.Percent, r.Bytes, r.Rate, r.ETA)
+	if r.Total > 0 {
+		s += fmt.Sprintf(", pliki %d/%d", r.Total-r.ToCheck, r.Total)
+	}
+	return s
+}
+
+// scanRecords jest funkcją bufio.SplitFunc dzielącą wyjście rsync na
+// rekordy zakończone '\n' lub '\r' (rekordy postępu są nadpisywane w
+// tej samej linii terminala przez '\r').
+func scanRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// progressOptions zwraca opcje rsync potrzebne do raportowania postępu.
+// Opcja --no-inc-recursive powoduje, że rsync przed kopiowaniem zna
+// liczbę wszystkich plików, więc procent wykonania jest dokładny.
+func progressOptions() []string {
+	return []string{"--info=progress2", "--no-inc-recursive"}
+}
+
+// Typ progressReporter pokazuje i zapisuje postęp snapshotu.
+type progressReporter struct {
+	src, dst string
+	begin    time.Time
+	last     time.Time // czas ostatniego logowania postępu
+	tty      bool      // stderr jest terminalem
+	line     bool      // na terminalu jest pokazana linia postępu
+	rec      progressRecord
+}
+
+// newProgressReporter tworzy progressReporter dla snapshotu src do dst
+// i zapisuje początkowy stan do StatusFile.
+func newProgressReporter(src, dst string) *progressReporter {
+	p := &progressReporter{
+		src:   src,
+		dst:   dst,
+		begin: time.Now(),
+		last:  time.Now(),
+	}
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		p.tty = true
+	}
+	p.writeStatus("running", nil)
+	return p
+}
+
+// update obsługuje rekord postępu rec.
+func (p *progressReporter) update(rec progressRecord) {
+	p.rec = rec
+	if p.tty {
+		// '\033[K' - wyczyszczenie reszty linii
+		fmt.Fprintf(os.Stderr, "\r%s\033[K", rec)
+		p.line = true
+	}
+	if time.Since(p.last) < ProgressInterval {
+		return
+	}
+	p.last = time.Now()
+	p.log("postęp: %s", rec)
+	p.writeStatus("running", nil)
+}
+
+// log loguje komunikat o postępie. Na terminalu komunikat jest
+// zapisywany tylko do LogFile, żeby nie przerywać linii postępu.
+func (p *progressReporter) log(format string, args ...interface{}) {
+	if p.tty {
+		logOnly(format, args...)
+		return
+	}
+	info(format, args...)
+}
+
+// finish kończy raportowanie postępu; err jest wynikiem snapshotu.
+func (p *progressReporter) finish(err error) {
+	if p.line {
+		fmt.Fprintln(os.Stderr)
+		p.line = false
+	}
+	state := "done"
+	if err != nil {
+		state = "failed"
+	}
+	p.writeStatus(state, err)
+}
+
+// writeStatus zapisuje stan snapshotu do pliku StatusFile w postaci
+// linii "klucz: wartość". Plik jest zamieniany atomowo (przez rename),
+// więc inne programy zawsze czytają kompletny stan. Błąd zapisu jest
+// tylko logowany.
+func (p *progressReporter) writeStatus(state string, err error) {
+	if StatusFile == "" {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "state: %s\n", state)
+	fmt.Fprintf(&b, "src: %s\n", p.src)
+	fmt.Fprintf(&b, "dst: %s\n", p.dst)
+	fmt.Fprintf(&b, "pid: %d\n", os.Getpid())
+	fmt.Fprintf(&b, "begin: %s\n", p.begin.Format(time.RFC3339))
+	fmt.Fprintf(&b, "update: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "percent: %d\n", p.rec.Percent)
+	fmt.Fprintf(&b, "bytes: %s\n", p.rec.Bytes)
+	fmt.Fprintf(&b, "rate: %s\n", p.rec.Rate)
+	fmt.Fprintf(&b, "eta: %s\n", p.rec.ETA)
+	fmt.Fprintf(&b, "files: %d\n", p.rec.Total-p.rec.ToCheck)
+	fmt.Fprintf(&b, "total: %d\n", p.rec.Total)
+	if err != nil {
+		fmt.Fprintf(&b, "error: %s\n", strings.Replace(err.Error(), "\n", " ", -1))
+	}
+
+	tmp := filepath.Join(filepath.Dir(StatusFile), "."+filepath.Base(StatusFile)+".tmp")
+	werr := os.WriteFile(tmp, []byte(b.String()), 0644)
+	if werr == nil {
+		werr = os.Rename(tmp, StatusFile)
+	}
+	if werr != nil {
+		p.log("warning: błąd zapisu pliku statusu: %s", werr)
+	}
+}
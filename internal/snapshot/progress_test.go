@@ -0,0 +1,65 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProgress(t *testing.T) {
+	var tests = []struct {
+		line string         // rekord z wyjścia rsync
+		ok   bool           // czy jest rekordem postępu
+		rec  progressRecord // oczekiwany wynik
+	}{
+		{
+			"      1,234,567  12%   10.50MB/s    0:01:23 (xfr#5, to-chk=100/200)",
+			true,
+			progressRecord{"1234567", 12, "10.50MB/s", "0:01:23", 5, 100, 200},
+		},
+		{
+			"         32,768   0%    0.00kB/s    0:00:00",
+			true,
+			progressRecord{"32768", 0, "0.00kB/s", "0:00:00", 0, 0, 0},
+		},
+		{
+			"          1.23G 100%   50.12MB/s    0:00:25 (xfr#9, ir-chk=0/10)",
+			true,
+			progressRecord{"1.23G", 100, "50.12MB/s", "0:00:25", 9, 0, 10},
+		},
+		{"home/adbr/12% rabatu.txt", false, progressRecord{}},
+		{"sending incremental file list", false, progressRecord{}},
+		{"", false, progressRecord{}},
+	}
+
+	for _, test := range tests {
+		rec, ok := parseProgress(test.line)
+		if ok != test.ok || !reflect.DeepEqual(rec, test.rec) {
+			t.Errorf("parseProgress(%q) = %+v, %v; oczekiwane %+v, %v",
+				test.line, rec, ok, test.rec, test.ok)
+		}
+	}
+}
+
+func TestScanRecords(t *testing.T) {
+	out := "a/b\n   10  50%  1MB/s 0:00:01\r   20 100%  1MB/s 0:00:00 (xfr#1, to-chk=0/2)\nc"
+	want := []string{
+		"a/b",
+		"   10  50%  1MB/s 0:00:01",
+		"   20 100%  1MB/s 0:00:00 (xfr#1, to-chk=0/2)",
+		"c",
+	}
+
+	var got []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Split(scanRecords)
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scanRecords: %q, oczekiwane %q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Typ Retention określa zasady usuwania starych snapshotów, gdy
+// snapshoty zajmują zbyt dużo miejsca. Zerowa wartość pola oznacza
+// brak ograniczenia.
+type Retention struct {
+	MaxSize int64   // maksymalne miejsce zajęte przez snapshoty (bajty)
+	MinFree float64 // minimalny procent wolnego miejsca na filesystemie
+	Keep    int     // liczba najnowszych snapshotów, które nie są usuwane
+}
+
+// Prune usuwa najstarsze snapshoty z katalogu dst dopóki nie zostaną
+// spełnione ograniczenia r. Nie są usuwane snapshoty przypięte,
+// snapshot wskazywany przez 'last' ani r.Keep najnowszych snapshotów.
+// Liczba usuwanych snapshotów jest wyznaczana na podstawie
+// przewidywanego zwolnionego miejsca (DiskUsage) z uwzględnieniem
+// hard linków między snapshotami.
+func Prune(dst string, r Retention) error {
+	if err := r.Check(); err != nil {
+		return err
+	}
+	if r.MaxSize <= 0 && r.MinFree <= 0 {
+		return nil
+	}
+
+	u, err := DiskUsage(dst)
+	if err != nil {
+		return err
+	}
+
+	var total, avail int64
+	if r.MinFree > 0 {
+		total, avail, err = diskSpace(dst)
+		if err != nil {
+			return err
+		}
+	}
+	need := r.need(u.Total, total, avail)
+	if need <= 0 {
+		info("snapshoty mieszczą się w limicie miejsca")
+		return nil
+	}
+	info("do zwolnienia: %d bajtów", need)
+
+	del, freed, err := pruneSelect(dst, u, r.Keep, need)
+	if err != nil {
+		return err
+	}
+	if freed < need {
+		warning("usunięcie wszystkich możliwych snapshotów zwolni tylko %d bajtów", freed)
+	}
+
+	for _, name := range del {
+		err := Delete(dst, name)
+		if err != nil {
+			return err
+		}
+	}
+	info("usunięto snapshotów: %d, zwolnione miejsce (przewidywane): %d bajtów", len(del), freed)
+	return nil
+}
+
+// Check sprawdza poprawność zasad r.
+func (r Retention) Check() error {
+	switch {
+	case r.MaxSize < 0:
+		return fmt.Errorf("ujemny limit miejsca %d", r.MaxSize)
+	case !(r.MinFree >= 0 && r.MinFree <= 100):
+		return fmt.Errorf("błędny procent wolnego miejsca %g", r.MinFree)
+	case r.Keep < 0:
+		return fmt.Errorf("ujemna liczba zachowywanych snapshotów %d", r.Keep)
+	}
+	return nil
+}
+
+// need zwraca liczbę bajtów, które trzeba zwolnić, żeby snapshoty
+// zajmujące used bajtów spełniały ograniczenia r na filesystemie o
+// rozmiarze total z avail wolnymi bajtami.
+func (r Retention) need(used, total, avail int64) int64 {
+	var need int64
+	if r.MaxSize > 0 && used > r.MaxSize {
+		need = used - r.MaxSize
+	}
+	if r.MinFree > 0 {
+		min := int64(r.MinFree / 100 * float64(total))
+		if avail < min && min-avail > need {
+			need = min - avail
+		}
+	}
+	return need
+}
+
+// pruneSelect wybiera najmniejszą liczbę najstarszych snapshotów
+// spośród pruneCandidates, których usunięcie zwolni need bajtów.
+// Zwraca nazwy wybranych snapshotów i przewidywane zwolnione miejsce,
+// które jest mniejsze od need, jeśli nie wystarczy usunięcie
+// wszystkich kandydatów.
+func pruneSelect(dst string, u *Usage, keep int, need int64) ([]string, int64, error) {
+	candidates, err := pruneCandidates(dst, u, keep)
+	if err != nil {
+		return nil, 0, err
+	}
+	var del []string
+	var freed int64
+	for _, name := range candidates {
+		if freed >= need {
+			break
+		}
+		del = append(del, name)
+		freed = u.Freed(del)
+	}
+	return del, freed, nil
+}
+
+// pruneCandidates zwraca nazwy snapshotów, które mogą być usunięte,
+// od najstarszego. Pomija snapshoty przypięte, wskazywany przez
+// 'last' i keep najnowszych.
+func pruneCandidates(dst string, u *Usage, keep int) ([]string, error) {
+	last, err := os.Readlink(filepath.Join(dst, "last"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	last = filepath.Base(last)
+
+	n := len(u.Snapshots) - keep
+	var names []string
+	for i := 0; i < n; i++ {
+		name := u.Snapshots[i].Snapshot
+		if name == last {
+			continue
+		}
+		pinned, err := IsPinned(dst, name)
+		if err != nil {
+			return nil, err
+		}
+		if pinned {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,105 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPruneSelect(t *testing.T) {
+	dst := t.TempDir()
+	names := []string{
+		"2015-02-10T18:07:39",
+		"2015-02-11T18:07:39",
+		"2015-02-12T18:07:39",
+		"2015-02-13T18:07:39",
+		"2015-02-14T18:07:39",
+	}
+	// każdy snapshot ma 100 bajtów wyłącznych, a dwa najstarsze
+	// współdzielą 50 bajtów
+	u := &Usage{inodes: make(map[inodeKey]*inodeUsage)}
+	for i, name := range names {
+		u.Snapshots = append(u.Snapshots, SnapshotUsage{Snapshot: name})
+		u.inodes[inodeKey{ino: uint64(i)}] = &inodeUsage{size: 100, snaps: []int{i}, links: 1, nlink: 1}
+	}
+	u.inodes[inodeKey{ino: 100}] = &inodeUsage{size: 50, snaps: []int{0, 1}, links: 2, nlink: 2}
+
+	err := os.Mkdir(filepath.Join(dst, pinsDir), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(pinFile(dst, names[2]), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(names[3], filepath.Join(dst, "last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		keep  int
+		need  int64
+		del   []string
+		freed int64
+	}{
+		{1, 0, nil, 0},
+		{1, 100, names[:1], 100},
+		{1, 101, names[:2], 250},
+		// przypięty names[2] i wskazywany przez 'last' names[3]
+		// nie są usuwane
+		{1, 1000, names[:2], 250},
+		{0, 1000, []string{names[0], names[1], names[4]}, 350},
+		{3, 1000, names[:2], 250},
+		{4, 1000, names[:1], 100},
+		{10, 1000, nil, 0},
+	}
+	for _, test := range tests {
+		del, freed, err := pruneSelect(dst, u, test.keep, test.need)
+		if err != nil {
+			t.Fatalf("pruneSelect: %s", err)
+		}
+		if !reflect.DeepEqual(del, test.del) || freed != test.freed {
+			t.Errorf("pruneSelect(keep: %d, need: %d) = %q, %d, oczekiwane %q, %d",
+				test.keep, test.need, del, freed, test.del, test.freed)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	var tests = []struct {
+		r                  Retention
+		used, total, avail int64
+		need               int64
+	}{
+		{Retention{}, 5000, 10000, 0, 0},
+		{Retention{MaxSize: 1000}, 1500, 0, 0, 500},
+		{Retention{MaxSize: 1000}, 900, 0, 0, 0},
+		{Retention{MinFree: 10}, 0, 10000, 500, 500},
+		{Retention{MinFree: 10}, 0, 10000, 2000, 0},
+		{Retention{MaxSize: 1000, MinFree: 10}, 1200, 10000, 700, 300},
+		{Retention{MaxSize: 1000, MinFree: 10}, 1500, 10000, 700, 500},
+	}
+	for _, test := range tests {
+		need := test.r.need(test.used, test.total, test.avail)
+		if need != test.need {
+			t.Errorf("%+v.need(%d, %d, %d) = %d, oczekiwane %d",
+				test.r, test.used, test.total, test.avail, need, test.need)
+		}
+	}
+
+	for _, r := range []Retention{{Keep: -1}, {MaxSize: -1}, {MinFree: -1}, {MinFree: 101}} {
+		if err := r.Check(); err == nil {
+			t.Errorf("%+v.Check() nie zwrócił błędu", r)
+		}
+		if err := Prune(t.TempDir(), r); err == nil {
+			t.Errorf("Prune z %+v nie zwrócił błędu", r)
+		}
+	}
+	if err := (Retention{MaxSize: 1, MinFree: 100, Keep: 0}).Check(); err != nil {
+		t.Errorf("Check: %s", err)
+	}
+}
@@ -0,0 +1,213 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Replicate kopiuje do katalogu to snapshoty z katalogu from, których
+// brakuje w to. Snapshoty są kopiowane chronologicznie poleceniem rsync
+// z opcją --link-dest wskazującą na poprzedni snapshot w to, więc w to
+// pliki są współdzielone przez hard linki tak samo jak w from. Snapshoty
+// bez znacznika zakończenia (metadanych ze statusem "complete") są
+// pomijane. Kopiowane są też informacje o przypięciu snapshotów (razem
+// z odebraniem prawa zapisu katalogom snapshotu, jeśli pin ma
+// ReadOnly). Na koniec symlink 'last' w to jest ustawiany na ten sam
+// snapshot co w from (lub na najnowszy kompletny, jeśli tamtego nie
+// skopiowano).
+func Replicate(from, to string) error {
+	info("=== początek replikacji (%s)", timestamp())
+	info("from: %q", from)
+	info("to: %q", to)
+	begin := time.Now()
+
+	src, err := listSnapshots(from)
+	if err != nil {
+		return err
+	}
+	dst, err := listSnapshots(to)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool)
+	for _, name := range dst {
+		have[name] = true
+	}
+
+	n := 0
+	for _, name := range src {
+		if have[name] {
+			continue
+		}
+		ok, err := isComplete(localTransport{}, from, name, globalNaming())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			warning("snapshot %q nie ma znacznika zakończenia - pominięty", name)
+			continue
+		}
+		err = replicateSnapshot(from, to, name, dst)
+		if err != nil {
+			return err
+		}
+		dst = append(dst, name)
+		sortNames(dst)
+		n++
+	}
+	info("skopiowane snapshoty: %d", n)
+
+	err = replicatePins(from, to)
+	if err != nil {
+		return err
+	}
+
+	// ustawienie symlinku 'last' tak jak w from
+	last, err := os.Readlink(filepath.Join(from, "last"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	last = filepath.Base(last)
+	if !containsName(dst, last) {
+		last = newestComplete(localTransport{}, to, dst, globalNaming())
+		if last == "" {
+			return nil
+		}
+	}
+	cur, err := os.Readlink(filepath.Join(to, "last"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if filepath.Base(cur) != last {
+		err = setLast(localTransport{}, to, last)
+		if err != nil {
+			return err
+		}
+	}
+
+	end := time.Now()
+	info("koniec replikacji, czas trwania: %s", end.Sub(begin))
+	return nil
+}
+
+// replicateSnapshot kopiuje snapshot name z katalogu from do to.
+// Argument have zawiera posortowane snapshoty istniejące w to; jako
+// --link-dest jest używany najnowszy z nich starszy od name.
+func replicateSnapshot(from, to, name string, have []string) error {
+	info("kopiowanie snapshotu %q", name)
+	m, err := ReadMeta(from, name)
+	if os.IsNotExist(err) {
+		// snapshot utworzony przez starszą wersję programu
+		m, err = legacyMeta(filepath.Join(from, name))
+	}
+	if err != nil {
+		return err
+	}
+	snapshotdir, err := makeSnapshotDir(localTransport{}, to)
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	args = append(args, strings.Fields(RsyncOptions)...)
+	if prev := previousSnapshot(have, name); prev != "" {
+		// opcja --link-dest wymaga bezwzględnej nazwy katalogu
+		prev, err := filepath.Abs(filepath.Join(to, prev))
+		if err != nil {
+			return err
+		}
+		args = append(args, "--link-dest="+prev)
+	}
+	// '/' na końcu - kopiowana jest zawartość katalogu
+	args = append(args, filepath.Join(from, name)+"/", snapshotdir)
+
+	errs, err := runRsync(args, nil)
+	if len(errs) != 0 {
+		warning("komunikaty błędów rsync: %s", errs)
+	}
+	if err != nil {
+		return err
+	}
+	// metadane (znacznik zakończenia) są kopiowane z from
+	return finalizeSnapshot(localTransport{}, to, snapshotdir, name, m)
+}
+
+// replicatePins kopiuje z katalogu from do to informacje o przypięciu
+// snapshotów, które istnieją w to i nie są w to przypięte. Jeśli
+// przypięty snapshot ma katalogi tylko do odczytu to katalogom
+// snapshotu w to jest też odbierane prawo zapisu. Jako oryginalne
+// prawa dostępu są zapamiętywane prawa katalogów w to, a dla
+// katalogów skopiowanych już bez prawa zapisu - prawa z pinu w from.
+func replicatePins(from, to string) error {
+	pins, err := Pins(from)
+	if err != nil {
+		return err
+	}
+	for _, pin := range pins {
+		if _, err := os.Stat(filepath.Join(to, pin.Snapshot)); err != nil {
+			continue
+		}
+		pinned, err := IsPinned(to, pin.Snapshot)
+		if err != nil {
+			return err
+		}
+		if pinned {
+			continue
+		}
+		info("kopiowanie przypięcia snapshotu %q", pin.Snapshot)
+		if !pin.ReadOnly {
+			err = writePin(to, pin)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		dir := filepath.Join(to, pin.Snapshot)
+		modes, err := dirModes(dir)
+		if err != nil {
+			return err
+		}
+		for rel, mode := range pin.modes {
+			if _, ok := modes[rel]; ok {
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(dir, rel)); err == nil {
+				modes[rel] = mode
+			}
+		}
+		pin.modes = modes
+		err = writePin(to, pin)
+		if err != nil {
+			return err
+		}
+		info("odebranie prawa zapisu katalogom snapshotu %q", pin.Snapshot)
+		err = setReadOnly(dir, modes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// previousSnapshot zwraca najnowszy snapshot z posortowanej listy names
+// starszy od snapshotu name lub "" jeśli nie ma takiego snapshotu.
+func previousSnapshot(names []string, name string) string {
+	id, err := ParseID(name)
+	if err != nil {
+		return ""
+	}
+	prev := ""
+	for _, s := range names {
+		sid, err := ParseID(s)
+		if err != nil || !sid.Before(id) {
+			break
+		}
+		prev = s
+	}
+	return prev
+}
@@ -0,0 +1,196 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPreviousSnapshot(t *testing.T) {
+	names := []string{"2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-13T18:07:39"}
+	var tests = []struct {
+		name string
+		prev string
+	}{
+		{"2015-02-09T18:07:39", ""},
+		{"2015-02-10T18:07:39", ""},
+		{"2015-02-12T18:07:39", "2015-02-11T18:07:39"},
+		{"2015-02-14T18:07:39", "2015-02-13T18:07:39"},
+		{"snapshot", ""},
+	}
+	for _, test := range tests {
+		prev := previousSnapshot(names, test.name)
+		if prev != test.prev {
+			t.Errorf("previousSnapshot(%q) = %q, oczekiwane %q", test.name, prev, test.prev)
+		}
+	}
+}
+
+func TestReplicatePins(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	from := t.TempDir()
+	to := t.TempDir()
+	names := []string{"2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-12T18:07:39"}
+	for _, name := range names {
+		err := os.MkdirAll(filepath.Join(from, name, "a"), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// names[0] skopiowany przed przypięciem, names[1] po przypięciu
+	// z -readonly (rsync zachował prawa katalogu "a"), names[2] nie
+	// został skopiowany
+	err := os.MkdirAll(filepath.Join(to, names[0], "a"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Join(to, names[1], "a"), 0555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		err := PinSnapshot(from, name, "test", name != names[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			Unpin(from, name)
+		}
+		os.Chmod(filepath.Join(to, names[0]), 0755)
+		os.Chmod(filepath.Join(to, names[1]), 0755)
+	}()
+
+	err = replicatePins(from, to)
+	if err != nil {
+		t.Fatalf("replicatePins: %s", err)
+	}
+	pinned, err := readPinned(localTransport{}, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pinned, names[:2]) {
+		t.Errorf("przypięte snapshoty w to: %q, oczekiwane %q", pinned, names[:2])
+	}
+	for _, name := range names[:2] {
+		for _, rel := range []string{".", "a"} {
+			fi, err := os.Stat(filepath.Join(to, name, rel))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode()&0222 != 0 {
+				t.Errorf("katalog %q snapshotu %q ma prawa %v", rel, name, fi.Mode())
+			}
+		}
+	}
+
+	// odpięcie w to przywraca prawa dostępu z from
+	for _, name := range names[:2] {
+		err := Unpin(to, name)
+		if err != nil {
+			t.Fatalf("Unpin: %s", err)
+		}
+		for _, rel := range []string{".", "a"} {
+			fi, err := os.Stat(filepath.Join(to, name, rel))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != 0755 {
+				t.Errorf("katalog %q snapshotu %q po odpięciu ma prawa %v", rel, name, fi.Mode().Perm())
+			}
+		}
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	if _, err := exec.LookPath(RsyncCommand); err != nil {
+		t.Skipf("brak polecenia %s", RsyncCommand)
+	}
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	from := t.TempDir()
+	to := t.TempDir()
+	names := []string{"2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-12T18:07:39"}
+	for i, name := range names {
+		dir := filepath.Join(from, name)
+		err := os.Mkdir(dir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			err = os.WriteFile(filepath.Join(dir, "a"), []byte("abc"), 0644)
+		} else {
+			err = os.Link(filepath.Join(from, names[0], "a"), filepath.Join(dir, "a"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := StatusComplete
+		if i == 2 {
+			status = "partial"
+		}
+		err = writeMeta(localTransport{}, from, name, &Meta{Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Symlink(names[1], filepath.Join(from, "last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Replicate(from, to)
+	if err != nil {
+		t.Fatalf("Replicate: %s", err)
+	}
+	got, err := listSnapshots(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, names[:2]) {
+		t.Errorf("snapshoty w to: %q, oczekiwane %q", got, names[:2])
+	}
+	for _, name := range names[:2] {
+		ok, err := isComplete(localTransport{}, to, name, globalNaming())
+		if err != nil || !ok {
+			t.Errorf("isComplete(%q) = %v, %v", name, ok, err)
+		}
+	}
+	link, err := os.Readlink(filepath.Join(to, "last"))
+	if err != nil || link != names[1] {
+		t.Errorf("symlink 'last' wskazuje na %q (%v), oczekiwane %q", link, err, names[1])
+	}
+	fi0, err := os.Stat(filepath.Join(to, names[0], "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi1, err := os.Stat(filepath.Join(to, names[1], "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(fi0, fi1) {
+		t.Errorf("pliki w to nie są hard linkami")
+	}
+
+	// ponowna replikacja niczego nie kopiuje
+	err = Replicate(from, to)
+	if err != nil {
+		t.Fatalf("ponowna Replicate: %s", err)
+	}
+	got, err = listSnapshots(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, names[:2]) {
+		t.Errorf("snapshoty w to po ponownej replikacji: %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Rodzaje komunikatów błędów wypisywanych przez rsync na stderr.
+const (
+	RsyncPermission = "permission" // brak uprawnień do pliku
+	RsyncVanished   = "vanished"   // plik zniknął w czasie kopiowania
+	RsyncIO         = "io"         // błąd wejścia/wyjścia
+	RsyncNoSpace    = "nospace"    // brak miejsca na dysku
+	RsyncConnection = "connection" // błąd połączenia ze zdalnym hostem
+	RsyncPartial    = "partial"    // nie wszystkie pliki zostały skopiowane
+	RsyncOther      = "other"      // inne komunikaty
+)
+
+// rsyncErrorKinds zawiera rodzaje komunikatów błędów rsync i
+// fragmenty komunikatów, po których są rozpoznawane. Kolejność jest
+// kolejnością sprawdzania i wypisywania w podsumowaniu.
+var rsyncErrorKinds = []struct {
+	kind     string
+	patterns []string
+}{
+	{RsyncPermission, []string{"Permission denied", "Operation not permitted"}},
+	{RsyncVanished, []string{"file has vanished", "some files vanished"}},
+	{RsyncIO, []string{"Input/output error", "IO error encountered"}},
+	{RsyncNoSpace, []string{"No space left on device", "Disk quota exceeded"}},
+	{RsyncConnection, []string{"connection unexpectedly closed",
+		"Connection refused", "error in rsync protocol data stream",
+		"Broken pipe", "timeout"}},
+	{RsyncPartial, []string{"some files/attrs were not transferred"}},
+}
+
+// classifyRsyncError zwraca rodzaj komunikatu błędu rsync line.
+func classifyRsyncError(line string) string {
+	for _, k := range rsyncErrorKinds {
+		for _, p := range k.patterns {
+			if strings.Contains(line, p) {
+				return k.kind
+			}
+		}
+	}
+	return RsyncOther
+}
+
+// Typ RsyncErrors zawiera liczbę komunikatów błędów rsync każdego
+// rodzaju.
+type RsyncErrors map[string]int
+
+// String zwraca liczby komunikatów w postaci "permission: 2, vanished:
+// 1" w kolejności rodzajów z rsyncErrorKinds.
+func (e RsyncErrors) String() string {
+	var a []string
+	for _, k := range rsyncErrorKinds {
+		if n := e[k.kind]; n > 0 {
+			a = append(a, fmt.Sprintf("%s: %d", k.kind, n))
+		}
+	}
+	if n := e[RsyncOther]; n > 0 {
+		a = append(a, fmt.Sprintf("%s: %d", RsyncOther, n))
+	}
+	if len(a) == 0 {
+		return "brak"
+	}
+	return strings.Join(a, ", ")
+}
+
+// logRsyncErrors loguje jako ostrzeżenia linie czytane z r (stderr
+// rsync) i zwraca liczbę komunikatów każdego rodzaju. Po błędzie
+// czytania (np. zbyt długiej linii) reszta r jest czytana bez
+// logowania, żeby rsync nie zablokował się na zapisie do pełnego pipe.
+func logRsyncErrors(r io.Reader) (RsyncErrors, error) {
+	errs := make(RsyncErrors)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		kind := classifyRsyncError(line)
+		errs[kind]++
+		warning("rsync [%s]: %s", kind, line)
+	}
+	err := scanner.Err()
+	if err != nil {
+		io.Copy(io.Discard, r)
+	}
+	return errs, err
+}
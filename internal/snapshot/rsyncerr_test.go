@@ -0,0 +1,67 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRsyncErrors(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	stderr := `rsync: [sender] send_files failed to open "/home/adbr/.ssh/id_rsa": Permission denied (13)
+rsync: [sender] opendir "/home/adbr/private" failed: Permission denied (13)
+file has vanished: "/home/adbr/.cache/tmp123"
+rsync: [sender] read errors mapping "/home/adbr/disk.img": Input/output error (5)
+rsync: [receiver] write failed on "/backup/snapshot/big": No space left on device (28)
+
+rsync: something unexpected
+rsync error: some files/attrs were not transferred (see previous errors) (code 23) at main.c(1338) [sender=3.2.7]
+`
+	errs, err := logRsyncErrors(strings.NewReader(stderr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "permission: 2, vanished: 1, io: 1, nospace: 1, partial: 1, other: 1"
+	if errs.String() != want {
+		t.Errorf("logRsyncErrors: %q, oczekiwane %q", errs, want)
+	}
+}
+
+func TestLogRsyncErrorsLongLine(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+
+	// linia dłuższa niż bufor bufio.Scanner, a po niej dalsze
+	// komunikaty - zapis do pipe blokuje się, dopóki nie zostaną
+	// przeczytane, tak jak zapis rsync do stderr
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.WriteString(w, strings.Repeat("x", 100*1024)+"\n")
+		if err == nil {
+			_, err = io.WriteString(w, strings.Repeat("rsync: Permission denied (13)\n", 1000))
+		}
+		w.Close()
+		done <- err
+	}()
+
+	_, err := logRsyncErrors(r)
+	if err == nil {
+		t.Errorf("logRsyncErrors nie zwrócił błędu dla zbyt długiej linii")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("zapis do pipe: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		r.Close()
+		t.Errorf("reszta stderr nie została przeczytana")
+	}
+}
@@ -7,10 +7,12 @@ package snapshot
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,20 +20,46 @@ var (
 	RsyncCommand = "rsync"
 	RsyncOptions = "-avxH8"
 	LogFile      *os.File
+
+	// Output jest miejscem, gdzie są wypisywane komunikaty (poza
+	// LogFile) - np. os.Stderr, gdy stdout jest używany do
+	// eksportu snapshotu.
+	Output io.Writer = os.Stdout
 )
 
 // Snapshot kopiuje katalog src do dst używając polecenia rsync(1);
 // pomija pliki pasujące do wzorców w exclude. Argument exclude
 // zawiera listę wzorców ignorowanych plików w postaci
-// "pattern,pattern,...".
-func Snapshot(src, dst, exclude string) error {
+// "pattern,pattern,...". Katalogi src i dst mogą być zdalne, w postaci
+// "host:/path" - wtedy rsync i operacje na katalogu dst używają ssh.
+// Katalogi src i dst mogą być też w module serwera rsync, w postaci
+// "rsync://host/module/path"; w module dst rsync kopiuje od razu do
+// katalogu z timestampem, a znacznikiem zakończenia są metadane
+// zapisywane po zakończeniu rsync.
+func Snapshot(src, dst, exclude string) (err error) {
 	info("=== początek snapshotu (%s)", timestamp())
 	info("src: %q", src)
 	info("dst: %q", dst)
 	begin := time.Now()
 
-	// utworzenie tymczasowego katalogu snapshot
-	snapshotdir, err := makeSnapshotDir(dst)
+	var progress *progressReporter
+	if Progress {
+		progress = newProgressReporter(src, dst)
+		defer func() { progress.finish(err) }()
+	}
+
+	t, dstdir := newTransport(dst)
+
+	// utworzenie tymczasowego katalogu snapshot; serwer rsync nie
+	// pozwala zmienić nazwy katalogu, więc w jego module rsync
+	// kopiuje od razu do katalogu z timestampem
+	var snapshotdir, name string
+	if isDaemon(dst) {
+		name, err = startDaemonSnapshot(t, dstdir, src, begin)
+		snapshotdir = filepath.Join(dstdir, name)
+	} else {
+		snapshotdir, err = makeSnapshotDir(t, dstdir)
+	}
 	if err != nil {
 		return err
 	}
@@ -42,80 +70,290 @@ func Snapshot(src, dst, exclude string) error {
 	// opcje standardowe
 	args = append(args, strings.Fields(RsyncOptions)...)
 
+	// opcja rsh dla zdalnych katalogów
+	if opt := rshOption(src, dst); opt != "" {
+		args = append(args, opt)
+	}
+
+	// opcja password-file dla serwera rsync
+	if opt := passwordOption(src, dst); opt != "" {
+		args = append(args, opt)
+	}
+
+	// opcje raportowania postępu
+	if progress != nil {
+		args = append(args, progressOptions()...)
+	}
+
 	// opcje exclude
 	opts := excludeOptions(exclude)
 	if len(opts) != 0 {
 		args = append(args, opts...)
 	}
 
-	// opcja linkdest
-	opt, err := linkdestOption(dst)
+	// opcje linkdest
+	opts, err = linkdestOptions(t, dstdir)
 	if err != nil {
 		return err
 	}
-	if len(opt) != 0 {
-		args = append(args, opt)
+	if len(opts) != 0 {
+		args = append(args, opts...)
 	}
 
 	// argumenty katalogi
-	args = append(args, src, snapshotdir)
+	args = append(args, src, rsyncLocation(dst, snapshotdir))
 
 	// uruchomienie polecenia rsync
+	errs, err := runRsync(args, progress)
+	if len(errs) != 0 {
+		warning("komunikaty błędów rsync: %s", errs)
+	}
+	if err != nil {
+		return err
+	}
+
+	// zapisanie snapshotu na dysk ze znacznikiem zakończenia i
+	// zmiana nazwy katalogu ze snapshotem na timestamp
+	meta := &Meta{
+		Status: StatusComplete,
+		Src:    src,
+		Begin:  begin,
+		End:    time.Now(),
+	}
+	if len(errs) != 0 {
+		meta.Errors = errs.String()
+	}
+	if isDaemon(dst) {
+		err = writeMeta(t, dstdir, name, meta)
+	} else {
+		name, err = snapshotName(t, dstdir, time.Now())
+		if err != nil {
+			return err
+		}
+		err = finalizeSnapshot(t, dstdir, snapshotdir, name, meta)
+	}
+	if err != nil {
+		return err
+	}
+
+	// ustawienie symlinku 'last' na ostatni snapshot
+	err = setLast(t, dstdir, name)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now()
+	info("koniec snapshotu, czas trwania: %s", end.Sub(begin))
+	return nil
+}
+
+// runRsync uruchamia polecenie rsync z argumentami args i loguje jego
+// wyjście. Jeśli progress nie jest nil, to rekordy postępu z wyjścia
+// rsync są przekazywane do progress. Stderr rsync jest logowany jako
+// ostrzeżenia; zwracana jest liczba komunikatów każdego rodzaju (także
+// gdy rsync zakończył się błędem).
+func runRsync(args []string, progress *progressReporter) (RsyncErrors, error) {
 	cmd := exec.Command(RsyncCommand, args...)
 	info("polecenie: %q", strings.Join(cmd.Args, " "))
-	cmd.Stderr = os.Stderr
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
 	}
 	err = cmd.Start()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// czytanie stderr równolegle ze stdout, żeby rsync nie
+	// zablokował się na zapisie do pełnego pipe
+	type result struct {
+		errs RsyncErrors
+		err  error
 	}
+	done := make(chan result, 1)
+	go func() {
+		errs, err := logRsyncErrors(stderr)
+		done <- result{errs, err}
+	}()
 
 	// czytanie i logowanie wyjścia z rsync
 	scanner := bufio.NewScanner(stdout)
+	if progress != nil {
+		scanner.Split(scanRecords)
+	}
 	for scanner.Scan() {
 		line := scanner.Text()
-		info("rsync: %s", line)
+		if progress == nil {
+			info("rsync: %s", line)
+			continue
+		}
+		if rec, ok := parseProgress(line); ok {
+			progress.update(rec)
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			progress.log("rsync: %s", line)
+		}
 	}
-	if err := scanner.Err(); err != nil {
-		return err
+	serr := scanner.Err()
+	if serr != nil {
+		// przeczytanie reszty wyjścia, żeby rsync mógł się
+		// zakończyć
+		io.Copy(io.Discard, stdout)
 	}
-
+	res := <-done
 	err = cmd.Wait()
-	if err != nil {
-		return err
+	if serr != nil {
+		return res.errs, serr
 	}
+	if res.err != nil {
+		return res.errs, res.err
+	}
+	return res.errs, err
+}
 
-	// zmiana nazwy katalogu ze snapshotem na timestamp
-	timestamp := timestamp()
-	timestampdir := filepath.Join(dst, timestamp)
-	info("zmiana nazwy katalogu %q na %q", "snapshot", timestamp)
-	err = os.Rename(snapshotdir, timestampdir)
+// snapshotName zwraca nazwę dla snapshotu utworzonego w czasie tm,
+// różną od nazw istniejących w katalogu dst i o identyfikatorze innym
+// niż identyfikatory istniejących snapshotów. Jeśli nazwa z samym
+// czasem jest zajęta (np. dwa snapshoty w tej samej sekundzie,
+// powtórzona godzina przy zmianie czasu albo snapshot z tym samym
+// czasem w innym formacie nazwy), to jest dodawany numer kolejny.
+func snapshotName(t Transport, dst string, tm time.Time) (string, error) {
+	names, err := readSnapshots(t, dst, globalNaming())
 	if err != nil {
-		return err
+		return "", err
+	}
+	ids := make([]ID, len(names))
+	for i, name := range names {
+		ids[i], _ = ParseID(name)
+	}
+	used := func(name string) bool {
+		id, _ := ParseID(name)
+		for _, other := range ids {
+			if other.Time.Equal(id.Time) && other.Seq == id.Seq {
+				return true
+			}
+		}
+		return false
 	}
 
-	// ustawienie symlinku 'last' na ostatni snapshot
-	info("zmiana symlinku %q -> %q", "last", timestamp)
-	lastdir := filepath.Join(dst, "last")
-	err = os.Remove(lastdir)
-	if err != nil {
+	for seq := 0; ; seq++ {
+		name := formatName(tm, seq, NameLayout, NameUTC)
+		if used(name) {
+			continue
+		}
+		_, err := t.Stat(filepath.Join(dst, name))
+		if err == nil {
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		// metadane pozostałe po snapshocie przerwanym przed
+		// zmianą nazwy katalogu roboczego
+		_, err = t.Stat(metaPath(dst, name))
 		if os.IsNotExist(err) {
-			info("warning: katalog %q nie istnieje - pierwszy snapshot?", lastdir)
-		} else {
-			return err
+			return name, nil
+		}
+		if err != nil {
+			return "", err
 		}
 	}
-	err = os.Symlink(timestamp, lastdir)
+}
+
+// finalizeSnapshot kończy snapshot w katalogu roboczym snapshotdir w
+// katalogu dst tak, żeby przerwanie programu (lub awaria systemu) w
+// dowolnym momencie nie zostawiło katalogu z timestampem, który
+// wygląda na kompletny, a nie jest. Dane snapshotu są zapisywane na
+// dysk, potem jest zapisywany znacznik zakończenia - plik z
+// metadanymi m snapshotu name w katalogu metaDir - i dopiero potem
+// nazwa katalogu jest zmieniana na name.
+func finalizeSnapshot(t Transport, dst, snapshotdir, name string, m *Meta) error {
+	info("zapisywanie snapshotu na dysk")
+	err := t.Sync(snapshotdir, true)
+	if err != nil {
+		return err
+	}
+	err = writeMeta(t, dst, name, m)
+	if err != nil {
+		return err
+	}
+	err = t.Sync(metaPath(dst, name), false)
+	if err != nil {
+		return err
+	}
+	err = t.Sync(filepath.Join(dst, metaDir), false)
+	if err != nil {
+		return err
+	}
+	err = renameSnapshotDir(t, dst, snapshotdir, name)
 	if err != nil {
 		return err
 	}
+	return t.Sync(dst, false)
+}
 
-	end := time.Now()
-	info("koniec snapshotu, czas trwania: %s", end.Sub(begin))
-	return nil
+// startDaemonSnapshot rozpoczyna snapshot katalogu src w katalogu dst
+// w module serwera rsync, w którym nie można zmienić nazwy katalogu
+// roboczego. Wybiera nazwę snapshotu dla czasu begin i zapisuje jego
+// metadane ze statusem StatusRunning, więc katalog snapshotu
+// przerwanego przed zapisaniem znacznika zakończenia (metadanych ze
+// statusem StatusComplete) nie jest uznawany za kompletny. Zwraca
+// nazwę snapshotu.
+func startDaemonSnapshot(t Transport, dst, src string, begin time.Time) (string, error) {
+	name, err := snapshotName(t, dst, begin)
+	if err != nil {
+		return "", err
+	}
+	info("katalog snapshotu: %q", name)
+	m := &Meta{
+		Status: StatusRunning,
+		Src:    src,
+		Begin:  begin,
+	}
+	err = writeMeta(t, dst, name, m)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// renameSnapshotDir zmienia nazwę katalogu roboczego snapshotdir w
+// katalogu dst na name.
+func renameSnapshotDir(t Transport, dst, snapshotdir, name string) error {
+	info("zmiana nazwy katalogu %q na %q", filepath.Base(snapshotdir), name)
+	return t.Rename(snapshotdir, filepath.Join(dst, name))
+}
+
+// Stała lastTmp jest nazwą tymczasowego symlinku w katalogu dst,
+// którego nazwa jest zmieniana na 'last'.
+const lastTmp = ".last.tmp"
+
+// setLast ustawia symlink 'last' w katalogu dst na snapshot name.
+// Nowy symlink jest tworzony pod tymczasową nazwą i zastępuje 'last'
+// przez zmianę nazwy, więc 'last' zawsze istnieje i wskazuje na stary
+// albo na nowy snapshot.
+func setLast(t Transport, dst, name string) error {
+	info("zmiana symlinku %q -> %q", "last", name)
+	tmp := filepath.Join(dst, lastTmp)
+	// symlink pozostały po przerwanym setLast
+	err := t.Remove(tmp)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = t.Symlink(name, tmp)
+	if err != nil {
+		return err
+	}
+	err = t.Replace(tmp, filepath.Join(dst, "last"))
+	if err != nil {
+		return err
+	}
+	return t.Sync(dst, false)
 }
 
 // excludeOptions parsuje string patterns zawierający listę wzorców i
@@ -134,44 +372,158 @@ func excludeOptions(patterns string) []string {
 	return opts
 }
 
-// linkdestOption tworzy i zwraca opcję '--link-dest' dla programu
-// rsync. jeśli w katalogu dst istnieje symlink 'last' wskazujący na
-// katalog z poprzednim snapshotem to zwraca utworzoną opcję. Jeśli
-// 'last' nie istnieje to loguje komunikat i zwraca string pusty.
+// Zmienne określające wybór katalogów dla opcji --link-dest. Pierwszym
+// katalogiem jest snapshot wskazywany przez 'last', a kolejnymi
+// najnowsze snapshoty - razem LinkDestCount katalogów. Jeśli
+// LinkDestPinned jest true, to są dodawane też przypięte snapshoty (od
+// najnowszego). Dzięki temu plik, którego nie ma w 'last' (np.
+// odtworzony ze starszego snapshotu lub brakujący w nieudanym
+// snapshocie), jest hard linkiem, a nie nową kopią.
+var (
+	LinkDestCount  = 1
+	LinkDestPinned = false
+)
+
+// Stała maxLinkDest jest maksymalną liczbą opcji --link-dest
+// przyjmowaną przez rsync.
+const maxLinkDest = 20
+
+// linkdestOptions tworzy i zwraca opcje '--link-dest' dla programu
+// rsync. Jeśli w katalogu dst istnieje symlink 'last' wskazujący na
+// kompletny snapshot, to zwraca opcję dla tego katalogu i opcje dla
+// innych kompletnych snapshotów wybranych zgodnie z LinkDestCount i
+// LinkDestPinned. Jeśli 'last' nie istnieje lub nie wskazuje na
+// kompletny snapshot, to zamiast niego jest używany najnowszy
+// kompletny snapshot, na który jest naprawiany symlink 'last'. Jeśli
+// w dst nie ma kompletnych snapshotów, to jest używany najnowszy
+// snapshot bez znacznika zakończenia, a jeśli nie ma żadnych
+// snapshotów, to loguje komunikat i zwraca pustą listę. Loguje też
+// ostrzeżenia o snapshotach bez metadanych (warnNoMeta).
 // Argument dst jest katalogiem docelowym, czyli katalogiem w którym
 // tworzone są snapshoty.
-func linkdestOption(dst string) (string, error) {
-	lastdir := filepath.Join(dst, "last")
-	// opcja --link-dest wymaga żeby jej argument był bezwzględną
-	// nazwą katalogu - jeśli nie jest to nie widzi katalogu
-	lastdir, err := filepath.Abs(lastdir)
+func linkdestOptions(t Transport, dst string) ([]string, error) {
+	names, err := readSnapshots(t, dst, globalNaming())
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	err = warnNoMeta(t, dst, names)
+	if err != nil {
+		return nil, err
+	}
+	last, err := lastSnapshot(t, dst, names)
+	if err != nil {
+		return nil, err
+	}
+	if last == "" {
+		last = newestComplete(t, dst, names, globalNaming())
+		switch {
+		case last != "":
+			warning("symlink 'last' jest naprawiany i wskazuje na najnowszy kompletny snapshot %q", last)
+			err = setLast(t, dst, last)
+			if err != nil {
+				return nil, err
+			}
+		case len(names) > 0:
+			// snapshoty bez znacznika zakończenia - użycie ich
+			// jako --link-dest jest bezpieczne, bo rsync tworzy
+			// hard linki tylko do plików takich samych jak w src
+			last = names[len(names)-1]
+			warning("brak snapshotów ze znacznikiem zakończenia w katalogu %q, jako --link-dest jest używany %q",
+				dst, last)
+		default:
+			warning("brak snapshotów w katalogu %q - pierwszy snapshot?", dst)
+			return nil, nil
+		}
 	}
 
-	fi, err := os.Stat(lastdir)
+	dirs := []string{last}
+	add := func(name string) {
+		for _, d := range dirs {
+			if d == name {
+				return
+			}
+		}
+		if len(dirs) == maxLinkDest {
+			return
+		}
+		if ok, err := isComplete(t, dst, name, globalNaming()); err != nil || !ok {
+			return
+		}
+		dirs = append(dirs, name)
+	}
+	for i := len(names) - 1; i >= 0 && len(dirs) < LinkDestCount; i-- {
+		add(names[i])
+	}
+	if LinkDestPinned {
+		pinned, err := readPinned(t, dst)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(pinned) - 1; i >= 0; i-- {
+			if containsName(names, pinned[i]) {
+				add(pinned[i])
+			}
+		}
+	}
+
+	var opts []string
+	for _, name := range dirs {
+		// opcja --link-dest wymaga żeby jej argument był
+		// bezwzględną nazwą katalogu - jeśli nie jest to nie widzi
+		// katalogu
+		dir, err := t.Abs(filepath.Join(dst, name))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, "--link-dest="+dir)
+	}
+	return opts, nil
+}
+
+// lastSnapshot zwraca nazwę snapshotu wskazywanego przez symlink 'last'
+// w katalogu dst. Zwraca "" jeśli 'last' nie istnieje albo nie wskazuje
+// na kompletny snapshot z listy names (w tym przypadku loguje
+// przyczynę).
+func lastSnapshot(t Transport, dst string, names []string) (string, error) {
+	lastdir := filepath.Join(dst, "last")
+	link, err := t.Readlink(lastdir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			info("warning: katalog %q nie istnieje - pierwszy snapshot?", lastdir)
 			return "", nil
 		}
-		return "", err
+		return "", fmt.Errorf("%q nie jest symlinkiem do snapshotu: %s", lastdir, err)
 	}
-	if !fi.IsDir() {
-		return "", fmt.Errorf("%q nie jest katalogiem", lastdir)
+	name := filepath.Base(link)
+	if !containsName(names, name) {
+		warning("symlink %q wskazuje na nieistniejący snapshot %q", lastdir, link)
+		return "", nil
 	}
-
-	return "--link-dest=" + lastdir, nil
+	ok, err := isComplete(t, dst, name, globalNaming())
+	if err != nil {
+		warning("snapshot %q: %s", name, err)
+		return "", nil
+	}
+	if !ok {
+		warning("symlink %q wskazuje na snapshot %q bez znacznika zakończenia", lastdir, name)
+		return "", nil
+	}
+	return name, nil
 }
 
-// info loguje sformatowany komunikat do stdout i pliku LogFile jeśli
+// logMutex synchronizuje logowanie komunikatów z wielu gorutyn (np.
+// stdout i stderr rsync).
+var logMutex sync.Mutex
+
+// info loguje sformatowany komunikat do Output i pliku LogFile jeśli
 // LogFile jest różny od nil. W przypadku błędu wywołuje panic.
 func info(format string, args ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	if !strings.HasSuffix(format, "\n") {
 		format += "\n"
 	}
 	format = "snapshot: " + format
-	fmt.Printf(format, args...)
+	fmt.Fprintf(Output, format, args...)
 	if LogFile != nil {
 		_, err := fmt.Fprintf(LogFile, format, args...)
 		if err != nil {
@@ -180,16 +532,39 @@ func info(format string, args ...interface{}) {
 	}
 }
 
+// warning loguje sformatowany komunikat tak jak info z poziomem
+// "warning: ".
+func warning(format string, args ...interface{}) {
+	info("warning: "+format, args...)
+}
+
+// logOnly loguje sformatowany komunikat tylko do pliku LogFile (jeśli
+// LogFile jest różny od nil). W przypadku błędu wywołuje panic.
+func logOnly(format string, args ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if LogFile == nil {
+		return
+	}
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	_, err := fmt.Fprintf(LogFile, "snapshot: "+format, args...)
+	if err != nil {
+		panic(err)
+	}
+}
+
 // makeSnapshotDir tworzy w docelowym katalogu dst, tymczasowy katalog
 // roboczy o nazwie 'snapshot', w którym będzie wykonywany aktualny
 // snapshot. Zwraca bezwzględną nazwę utworzonego katalogu i błąd
 // jeśli wystąpił.
-func makeSnapshotDir(dst string) (string, error) {
+func makeSnapshotDir(t Transport, dst string) (string, error) {
 	dir := filepath.Join(dst, "snapshot")
-	err := os.Mkdir(dir, 0755)
+	err := t.Mkdir(dir)
 	if err != nil {
 		if os.IsExist(err) {
-			info("warning: katalog \"snapshot\" już istnieje - nie dokończony poprzedni snapshot?")
+			warning("katalog \"snapshot\" już istnieje - nie dokończony poprzedni snapshot?")
 			return dir, nil
 		}
 		return "", err
@@ -198,8 +573,39 @@ func makeSnapshotDir(dst string) (string, error) {
 }
 
 // timestamp zwraca string z aktualną datą i czasem w formacie
-// 'yyyy-mm-ddThh:mm:ss'.
+// 'yyyy-mm-ddThh:mm:ss' (używany w logach).
 func timestamp() string {
 	t := time.Now()
-	return t.Format("2006-01-02T15:04:05")
+	return t.Format(timestampLayout)
+}
+
+// listSnapshots zwraca posortowane chronologicznie nazwy katalogów ze
+// snapshotami w katalogu dst. Pomija katalog roboczy 'snapshot',
+// symlink 'last' i inne pliki, których nazwy nie są timestampem.
+func listSnapshots(dst string) ([]string, error) {
+	return readSnapshots(localTransport{}, dst, globalNaming())
+}
+
+// readSnapshots działa tak jak listSnapshots dla katalogu dst
+// dostępnego przez Transport t i nazw snapshotów w formacie n.
+func readSnapshots(t Transport, dst string, n naming) ([]string, error) {
+	infos, err := t.ReadDir(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, fi := range infos {
+		if !fi.IsDir() {
+			continue
+		}
+		if _, err := n.parseID(fi.Name()); err != nil {
+			continue
+		}
+		names = append(names, fi.Name())
+	}
+	// nazwy w różnych formatach (np. z czasem UTC i lokalnym) nie
+	// sortują się leksykograficznie tak jak chronologicznie
+	n.sortNames(names)
+	return names, nil
 }
@@ -0,0 +1,138 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLinkdestOptions(t *testing.T) {
+	Output = io.Discard
+	defer func() { Output = os.Stdout }()
+	defer func(n int, p bool) { LinkDestCount, LinkDestPinned = n, p }(LinkDestCount, LinkDestPinned)
+
+	dst := t.TempDir()
+	names := []string{
+		"2015-02-10T18:07:39",
+		"2015-02-11T18:07:39",
+		"2015-02-12T18:07:39",
+		"2015-02-13T18:07:39",
+	}
+	for _, name := range names {
+		dir := filepath.Join(dst, name)
+		err := os.Mkdir(dir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeMeta(localTransport{}, dst, name, &Meta{Status: StatusComplete})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Mkdir(filepath.Join(dst, pinsDir), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(pinFile(dst, names[0]), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// najnowszy snapshot jest niekompletny
+	err = os.WriteFile(metaPath(dst, names[3]), []byte("status: partial\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt := func(name string) string {
+		return "--link-dest=" + filepath.Join(dst, name)
+	}
+	var tests = []struct {
+		last   string   // snapshot wskazywany przez 'last'
+		count  int      // LinkDestCount
+		pinned bool     // LinkDestPinned
+		opts   []string // oczekiwane opcje
+	}{
+		{names[2], 1, false, []string{opt(names[2])}},
+		{names[2], 3, false, []string{opt(names[2]), opt(names[1]), opt(names[0])}},
+		{names[1], 2, true, []string{opt(names[1]), opt(names[2]), opt(names[0])}},
+		{names[0], 1, true, []string{opt(names[0])}},
+		// brak lub błędny 'last' - najnowszy kompletny snapshot
+		{"", 1, false, []string{opt(names[2])}},
+		{"2015-02-14T18:07:39", 1, false, []string{opt(names[2])}},
+		{names[3], 2, false, []string{opt(names[2]), opt(names[1])}},
+	}
+
+	last := filepath.Join(dst, "last")
+	for _, test := range tests {
+		os.Remove(last)
+		if test.last != "" {
+			err := os.Symlink(test.last, last)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		LinkDestCount, LinkDestPinned = test.count, test.pinned
+		opts, err := linkdestOptions(localTransport{}, dst)
+		if err != nil {
+			t.Fatalf("linkdestOptions: %s", err)
+		}
+		if !reflect.DeepEqual(opts, test.opts) {
+			t.Errorf("linkdestOptions (last: %q, count: %d, pinned: %v) = %q, oczekiwane %q",
+				test.last, test.count, test.pinned, opts, test.opts)
+		}
+		// 'last' wskazuje na pierwszy katalog --link-dest
+		link, err := os.Readlink(last)
+		if err != nil || opt(link) != opts[0] {
+			t.Errorf("symlink 'last' wskazuje na %q (%v), oczekiwana opcja %q", link, err, opts[0])
+		}
+	}
+
+	opts, err := linkdestOptions(localTransport{}, t.TempDir())
+	if err != nil || opts != nil {
+		t.Errorf("linkdestOptions dla pustego katalogu = %q, %v", opts, err)
+	}
+
+	// snapshoty utworzone przez starszą wersję programu są
+	// kompletne - 'last' jest naprawiany
+	dst = t.TempDir()
+	for _, name := range names[:2] {
+		err := os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	LinkDestCount, LinkDestPinned = 2, false
+	opts, err = linkdestOptions(localTransport{}, dst)
+	if want := []string{opt(names[1]), opt(names[0])}; err != nil || !reflect.DeepEqual(opts, want) {
+		t.Errorf("linkdestOptions dla starszych snapshotów = %q, %v, oczekiwane %q", opts, err, want)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "last")); err != nil || link != names[1] {
+		t.Errorf("symlink 'last' dla starszych snapshotów wskazuje na %q (%v)", link, err)
+	}
+
+	// brak kompletnych snapshotów - najnowszy jest używany jako
+	// --link-dest, ale 'last' nie jest zmieniany
+	dst = t.TempDir()
+	for _, name := range names[:2] {
+		err := os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writeMeta(localTransport{}, dst, name, &Meta{Status: "partial"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts, err = linkdestOptions(localTransport{}, dst)
+	if want := []string{opt(names[1])}; err != nil || !reflect.DeepEqual(opts, want) {
+		t.Errorf("linkdestOptions dla niekompletnych snapshotów = %q, %v, oczekiwane %q", opts, err, want)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "last")); !os.IsNotExist(err) {
+		t.Errorf("symlink 'last' dla niekompletnych snapshotów: %v", err)
+	}
+}
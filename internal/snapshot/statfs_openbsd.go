@@ -0,0 +1,17 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import "syscall"
+
+// diskSpace zwraca rozmiar filesystemu, na którym jest katalog dir, i
+// ilość miejsca dostępnego dla użytkownika (w bajtach).
+func diskSpace(dir string) (total, avail int64, err error) {
+	var st syscall.Statfs_t
+	err = syscall.Statfs(dir, &st)
+	if err != nil {
+		return 0, 0, err
+	}
+	bsize := int64(st.F_bsize)
+	return int64(st.F_blocks) * bsize, st.F_bavail * bsize, nil
+}
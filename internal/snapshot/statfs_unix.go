@@ -0,0 +1,19 @@
+// 2026-10-18 adbr
+
+//go:build linux || darwin || freebsd
+
+package snapshot
+
+import "syscall"
+
+// diskSpace zwraca rozmiar filesystemu, na którym jest katalog dir, i
+// ilość miejsca dostępnego dla użytkownika (w bajtach).
+func diskSpace(dir string) (total, avail int64, err error) {
+	var st syscall.Statfs_t
+	err = syscall.Statfs(dir, &st)
+	if err != nil {
+		return 0, 0, err
+	}
+	bsize := int64(st.Bsize)
+	return int64(st.Blocks) * bsize, int64(st.Bavail) * bsize, nil
+}
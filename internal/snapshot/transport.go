@@ -0,0 +1,422 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Zmienne określające polecenie ssh(1) używane do dostępu do zdalnego
+// katalogu src lub dst (postaci "host:/path"). SSHCommand może
+// zawierać opcje, np. "ssh -p 2222"; SSHIdentity jest plikiem z
+// kluczem prywatnym (opcja -i).
+var (
+	SSHCommand  = "ssh"
+	SSHIdentity = ""
+)
+
+// Typ Transport wykonuje operacje na plikach w katalogu docelowym dst
+// potrzebne do utworzenia snapshotu: utworzenie katalogu roboczego,
+// zapisanie go na dysk i zmianę jego nazwy na timestamp, zmianę
+// symlinku 'last' i wybór katalogów dla opcji --link-dest. Są trzy
+// implementacje: lokalna, przez ssh i przez serwer rsync. Błędy mają
+// postać *os.PathError, więc można je sprawdzać przez os.IsExist i
+// os.IsNotExist.
+type Transport interface {
+	Mkdir(name string) error
+	Rename(oldname, newname string) error
+
+	// Replace działa tak jak Rename, ale zastępuje istniejący plik
+	// (nie katalog) newname, jeśli to możliwe - atomowo.
+	Replace(oldname, newname string) error
+	Remove(name string) error
+	Symlink(oldname, newname string) error
+	Stat(name string) (os.FileInfo, error)
+	Abs(name string) (string, error)
+
+	// ReadDir zwraca pliki w katalogu name (bez rozwijania
+	// symlinków).
+	ReadDir(name string) ([]os.FileInfo, error)
+	Readlink(name string) (string, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+
+	// Sync zapisuje na dysk plik lub katalog name, a jeśli tree
+	// jest true, to także wszystkie pliki w drzewie katalogu name.
+	Sync(name string, tree bool) error
+}
+
+// splitLocation dzieli nazwę katalogu loc postaci "host:/path" na nazwę
+// hosta i ścieżkę (tak jak rsync: dwukropek przed pierwszym '/'). Dla
+// katalogu postaci "rsync://host/module/path" zwraca
+// "rsync://host/module" i ścieżkę względem modułu. Dla katalogu
+// lokalnego zwraca pustą nazwę hosta.
+func splitLocation(loc string) (host, dir string) {
+	if strings.HasPrefix(loc, daemonPrefix) {
+		// a[0] - host, a[1] - moduł, a[2] - ścieżka
+		a := strings.SplitN(strings.TrimPrefix(loc, daemonPrefix), "/", 3)
+		if len(a) < 3 {
+			return loc, ""
+		}
+		return daemonPrefix + a[0] + "/" + a[1], strings.Trim(a[2], "/")
+	}
+	i := strings.Index(loc, ":")
+	if i <= 0 {
+		return "", loc
+	}
+	if j := strings.Index(loc, "/"); j >= 0 && j < i {
+		return "", loc
+	}
+	return loc[:i], loc[i+1:]
+}
+
+// IsRemote zwraca true jeśli loc jest zdalnym katalogiem postaci
+// "host:/path" lub "rsync://host/module/path".
+func IsRemote(loc string) bool {
+	host, _ := splitLocation(loc)
+	return host != ""
+}
+
+// isDaemon zwraca true jeśli loc jest katalogiem w module serwera rsync
+// postaci "rsync://host/module/path".
+func isDaemon(loc string) bool {
+	return strings.HasPrefix(loc, daemonPrefix)
+}
+
+// newTransport zwraca Transport dla katalogu loc i ścieżkę katalogu
+// bez nazwy hosta.
+func newTransport(loc string) (Transport, string) {
+	host, dir := splitLocation(loc)
+	if host == "" {
+		return localTransport{}, dir
+	}
+	if dir == "" {
+		dir = "."
+	}
+	if isDaemon(host) {
+		return &daemonTransport{url: host}, dir
+	}
+	return &sshTransport{host: host}, dir
+}
+
+// rsyncLocation zwraca argument rsync dla katalogu dir na hoście
+// katalogu loc.
+func rsyncLocation(loc, dir string) string {
+	host, _ := splitLocation(loc)
+	if host == "" {
+		return dir
+	}
+	if isDaemon(host) {
+		return host + "/" + strings.TrimPrefix(dir, "/")
+	}
+	return host + ":" + dir
+}
+
+// rshOption zwraca opcję -e dla rsync z poleceniem ssh, jeśli któryś z
+// katalogów locs jest zdalny (dostępny przez ssh).
+func rshOption(locs ...string) string {
+	for _, loc := range locs {
+		if IsRemote(loc) && !isDaemon(loc) {
+			return "--rsh=" + strings.Join(sshArgs(), " ")
+		}
+	}
+	return ""
+}
+
+// sshArgs zwraca polecenie ssh z opcjami.
+func sshArgs() []string {
+	args := strings.Fields(SSHCommand)
+	if SSHIdentity != "" {
+		args = append(args, "-i", SSHIdentity)
+	}
+	return args
+}
+
+// Typ localTransport wykonuje operacje na lokalnym filesystemie.
+type localTransport struct{}
+
+func (localTransport) Mkdir(name string) error {
+	return os.Mkdir(name, 0755)
+}
+
+func (localTransport) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (localTransport) Replace(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (localTransport) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (localTransport) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (localTransport) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (localTransport) Abs(name string) (string, error) {
+	return filepath.Abs(name)
+}
+
+func (localTransport) ReadDir(name string) ([]os.FileInfo, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+func (localTransport) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (localTransport) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (localTransport) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0644)
+}
+
+func (localTransport) Sync(name string, tree bool) error {
+	if !tree {
+		return syncFile(name)
+	}
+	return filepath.Walk(name, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil
+		}
+		return syncFile(path)
+	})
+}
+
+// syncFile zapisuje na dysk plik lub katalog name (fsync(2)).
+func syncFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Typ sshTransport wykonuje operacje na zdalnym hoście przez ssh(1)
+// wywołując polecenia powłoki.
+type sshTransport struct {
+	host string
+}
+
+// Kody wyjścia skryptów wykonywanych przez sshTransport oznaczające
+// błędy os.ErrNotExist i os.ErrExist.
+const (
+	exitNotExist = 3
+	exitExist    = 4
+)
+
+// run wykonuje na zdalnym hoście skrypt powłoki script i zwraca jego
+// stdout. Kody wyjścia exitNotExist i exitExist są zamieniane na
+// *os.PathError z operacją op i plikiem name.
+func (t *sshTransport) run(op, name, script string) (string, error) {
+	return t.runInput(op, name, script, nil)
+}
+
+// runInput działa tak jak run, a stdin skryptu jest czytany z input.
+func (t *sshTransport) runInput(op, name, script string, input []byte) (string, error) {
+	args := sshArgs()
+	args = append(args, t.host, script)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if e, ok := err.(*exec.ExitError); ok {
+			switch e.ExitCode() {
+			case exitNotExist:
+				return "", &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+			case exitExist:
+				return "", &os.PathError{Op: op, Path: name, Err: os.ErrExist}
+			}
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", &os.PathError{Op: op, Path: t.host + ":" + name, Err: fmt.Errorf("%s", msg)}
+	}
+	return stdout.String(), nil
+}
+
+func (t *sshTransport) Mkdir(name string) error {
+	n := shellQuote(name)
+	_, err := t.run("mkdir", name, fmt.Sprintf(
+		"if [ -e %s ]; then exit %d; fi; mkdir %s", n, exitExist, n))
+	return err
+}
+
+func (t *sshTransport) Rename(oldname, newname string) error {
+	o, n := shellQuote(oldname), shellQuote(newname)
+	// mv przeniósłby katalog do istniejącego katalogu newname
+	_, err := t.run("rename", oldname, fmt.Sprintf(
+		"if [ ! -e %s ]; then exit %d; fi; if [ -e %s ]; then exit %d; fi; mv %s %s",
+		o, exitNotExist, n, exitExist, o, n))
+	return err
+}
+
+func (t *sshTransport) Replace(oldname, newname string) error {
+	o, n := shellQuote(oldname), shellQuote(newname)
+	// mv -T (GNU) nie przenosi pliku do katalogu wskazywanego przez
+	// symlink newname; bez -T newname jest najpierw usuwany
+	_, err := t.run("rename", oldname, fmt.Sprintf(
+		"if [ ! -e %s ] && [ ! -L %s ]; then exit %d; fi; mv -T %s %s 2>/dev/null || { rm -f %s && mv %s %s; }",
+		o, o, exitNotExist, o, n, n, o, n))
+	return err
+}
+
+func (t *sshTransport) Remove(name string) error {
+	n := shellQuote(name)
+	_, err := t.run("remove", name, fmt.Sprintf(
+		"if [ ! -e %s ] && [ ! -L %s ]; then exit %d; fi; rm %s", n, n, exitNotExist, n))
+	return err
+}
+
+func (t *sshTransport) Symlink(oldname, newname string) error {
+	n := shellQuote(newname)
+	_, err := t.run("symlink", newname, fmt.Sprintf(
+		"if [ -e %s ] || [ -L %s ]; then exit %d; fi; ln -s %s %s",
+		n, n, exitExist, shellQuote(oldname), n))
+	return err
+}
+
+func (t *sshTransport) Stat(name string) (os.FileInfo, error) {
+	n := shellQuote(name)
+	out, err := t.run("stat", name, fmt.Sprintf(
+		"if [ ! -e %s ]; then exit %d; fi; if [ -d %s ]; then echo d; else echo f; fi",
+		n, exitNotExist, n))
+	if err != nil {
+		return nil, err
+	}
+	return newRemoteFileInfo(path.Base(name), strings.TrimSpace(out)), nil
+}
+
+func (t *sshTransport) ReadDir(name string) ([]os.FileInfo, error) {
+	n := shellQuote(name)
+	// wzorce '.[!.]*' i '..?*' - pliki zaczynające się od '.'
+	// (poza '.' i '..')
+	out, err := t.run("readdir", name, fmt.Sprintf(
+		`if [ ! -d %s ]; then exit %d; fi; cd %s || exit 1; `+
+			`for f in * .[!.]* ..?*; do `+
+			`if [ -L "$f" ]; then echo "l $f"; `+
+			`elif [ -d "$f" ]; then echo "d $f"; `+
+			`elif [ -e "$f" ]; then echo "f $f"; fi; done`,
+		n, exitNotExist, n))
+	if err != nil {
+		return nil, err
+	}
+	var infos []os.FileInfo
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 3 {
+			continue
+		}
+		infos = append(infos, newRemoteFileInfo(line[2:], line[:1]))
+	}
+	return infos, nil
+}
+
+func (t *sshTransport) Readlink(name string) (string, error) {
+	n := shellQuote(name)
+	out, err := t.run("readlink", name, fmt.Sprintf(
+		"if [ ! -L %s ]; then exit %d; fi; readlink %s", n, exitNotExist, n))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (t *sshTransport) ReadFile(name string) ([]byte, error) {
+	n := shellQuote(name)
+	out, err := t.run("open", name, fmt.Sprintf(
+		"if [ ! -e %s ]; then exit %d; fi; cat %s", n, exitNotExist, n))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+func (t *sshTransport) WriteFile(name string, data []byte) error {
+	_, err := t.runInput("open", name, "cat > "+shellQuote(name), data)
+	return err
+}
+
+// Sync wykonuje sync(1) na zdalnym hoście - nie ma przenośnego
+// polecenia zapisującego na dysk pojedynczy plik.
+func (t *sshTransport) Sync(name string, tree bool) error {
+	n := shellQuote(name)
+	_, err := t.run("sync", name, fmt.Sprintf(
+		"if [ ! -e %s ]; then exit %d; fi; sync", n, exitNotExist))
+	return err
+}
+
+func (t *sshTransport) Abs(name string) (string, error) {
+	if path.IsAbs(name) {
+		return path.Clean(name), nil
+	}
+	out, err := t.run("abs", name, "pwd")
+	if err != nil {
+		return "", err
+	}
+	return path.Join(strings.TrimSpace(out), name), nil
+}
+
+// shellQuote zwraca s w postaci bezpiecznej do użycia jako argument w
+// poleceniu powłoki.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// Typ remoteFileInfo jest os.FileInfo dla pliku na zdalnym hoście.
+// Zawiera tylko nazwę i typ pliku.
+type remoteFileInfo struct {
+	name string
+	mode os.FileMode
+}
+
+// newRemoteFileInfo zwraca remoteFileInfo dla pliku name o typie typ:
+// "d" - katalog, "l" - symlink, inne - zwykły plik.
+func newRemoteFileInfo(name, typ string) *remoteFileInfo {
+	fi := &remoteFileInfo{name: name}
+	switch typ {
+	case "d":
+		fi.mode = os.ModeDir
+	case "l":
+		fi.mode = os.ModeSymlink
+	}
+	return fi
+}
+
+func (fi *remoteFileInfo) Name() string       { return fi.name }
+func (fi *remoteFileInfo) Size() int64        { return 0 }
+func (fi *remoteFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *remoteFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *remoteFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *remoteFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,138 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitLocation(t *testing.T) {
+	var tests = []struct {
+		loc  string // nazwa katalogu
+		host string // oczekiwana nazwa hosta
+		dir  string // oczekiwana ścieżka
+	}{
+		{"/backup/home", "", "/backup/home"},
+		{"backup/home", "", "backup/home"},
+		{"host:/backup/home", "host", "/backup/home"},
+		{"adbr@host:backup", "adbr@host", "backup"},
+		{"host:", "host", ""},
+		{"./a:b", "", "./a:b"},
+		{":abc", "", ":abc"},
+		{"rsync://host/mod/backup/home", "rsync://host/mod", "backup/home"},
+		{"rsync://adbr@host:8730/mod/backup/", "rsync://adbr@host:8730/mod", "backup"},
+		{"rsync://host/mod", "rsync://host/mod", ""},
+	}
+
+	for _, test := range tests {
+		host, dir := splitLocation(test.loc)
+		if host != test.host || dir != test.dir {
+			t.Errorf("splitLocation(%q) = %q, %q; oczekiwane %q, %q",
+				test.loc, host, dir, test.host, test.dir)
+		}
+	}
+}
+
+// TestSSHTransport sprawdza sshTransport używając zamiast ssh
+// skryptu, który wykonuje polecenie lokalnie.
+func TestSSHTransport(t *testing.T) {
+	tmp := t.TempDir()
+	standin := filepath.Join(tmp, "ssh")
+	script := "#!/bin/sh\n# ostatni argument jest poleceniem\neval \"cmd=\\${$#}\"\nexec sh -c \"$cmd\"\n"
+	err := os.WriteFile(standin, []byte(script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(s string) { SSHCommand = s }(SSHCommand)
+	SSHCommand = standin
+
+	tr, dst := newTransport("host:" + tmp)
+	if _, ok := tr.(*sshTransport); !ok {
+		t.Fatalf("newTransport zwrócił %T, oczekiwany *sshTransport", tr)
+	}
+
+	dir := filepath.Join(dst, "snap shot")
+	if err := tr.Mkdir(dir); err != nil {
+		t.Fatalf("Mkdir: %s", err)
+	}
+	if err := tr.Mkdir(dir); !os.IsExist(err) {
+		t.Errorf("Mkdir istniejącego katalogu: %v, oczekiwany błąd os.ErrExist", err)
+	}
+	fi, err := tr.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		t.Errorf("Stat(%q) = %v, %v; oczekiwany katalog", dir, fi, err)
+	}
+	if _, err := tr.Stat(filepath.Join(dst, "brak")); !os.IsNotExist(err) {
+		t.Errorf("Stat nieistniejącego pliku: %v, oczekiwany błąd os.ErrNotExist", err)
+	}
+
+	ts := filepath.Join(dst, "2015-02-10T18:07:39")
+	if err := tr.Rename(dir, ts); err != nil {
+		t.Fatalf("Rename: %s", err)
+	}
+	if err := tr.Mkdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Rename(dir, ts); !os.IsExist(err) {
+		t.Errorf("Rename na istniejący katalog: %v, oczekiwany błąd os.ErrExist", err)
+	}
+
+	last := filepath.Join(dst, "last")
+	if err := tr.Remove(last); !os.IsNotExist(err) {
+		t.Errorf("Remove nieistniejącego pliku: %v, oczekiwany błąd os.ErrNotExist", err)
+	}
+	if err := tr.Symlink("2015-02-10T18:07:39", last); err != nil {
+		t.Fatalf("Symlink: %s", err)
+	}
+	link, err := tr.Readlink(last)
+	if err != nil || link != "2015-02-10T18:07:39" {
+		t.Errorf("symlink wskazuje na %q (%v)", link, err)
+	}
+
+	infos, err := tr.ReadDir(dst)
+	if err != nil {
+		t.Fatalf("ReadDir: %s", err)
+	}
+	types := make(map[string]os.FileMode)
+	for _, fi := range infos {
+		types[fi.Name()] = fi.Mode().Type()
+	}
+	want := map[string]os.FileMode{
+		"2015-02-10T18:07:39": os.ModeDir,
+		"snap shot":           os.ModeDir,
+		"last":                os.ModeSymlink,
+		"ssh":                 0,
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("ReadDir: %v, oczekiwane %v", types, want)
+	}
+
+	// zastąpienie symlinku 'last' wskazującego na katalog
+	if err := tr.Symlink("snap shot", filepath.Join(dst, lastTmp)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Replace(filepath.Join(dst, lastTmp), last); err != nil {
+		t.Fatalf("Replace: %s", err)
+	}
+	link, err = tr.Readlink(last)
+	if err != nil || link != "snap shot" {
+		t.Errorf("Replace: symlink wskazuje na %q (%v)", link, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "2015-02-10T18:07:39", lastTmp)); !os.IsNotExist(err) {
+		t.Errorf("Replace przeniósł symlink do katalogu wskazywanego przez 'last'")
+	}
+	if err := tr.Sync(dst, false); err != nil {
+		t.Errorf("Sync: %s", err)
+	}
+	if err := tr.Remove(last); err != nil {
+		t.Errorf("Remove: %s", err)
+	}
+
+	abs, err := tr.Abs(dst)
+	if err != nil || abs != dst {
+		t.Errorf("Abs(%q) = %q, %v", dst, abs, err)
+	}
+}
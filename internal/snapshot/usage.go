@@ -0,0 +1,153 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Typ SnapshotUsage zawiera zajętość miejsca przez jeden snapshot z
+// uwzględnieniem hard linków tworzonych przez rsync --link-dest.
+// Rozmiary są liczone w bajtach zajętych na dysku (bloki).
+type SnapshotUsage struct {
+	Snapshot  string // nazwa katalogu snapshotu (timestamp)
+	Exclusive int64  // i-węzły używane tylko przez ten snapshot
+	Shared    int64  // i-węzły współdzielone z innymi snapshotami
+	Freed     int64  // miejsce zwolnione po usunięciu snapshotu
+}
+
+// Typ Usage zawiera zajętość miejsca przez wszystkie snapshoty w
+// katalogu docelowym.
+type Usage struct {
+	Snapshots []SnapshotUsage // posortowane chronologicznie
+	Total     int64           // miejsce zajęte przez wszystkie snapshoty
+
+	// i-węzły znalezione w snapshotach - używane do
+	// przewidywania ile miejsca zwolni usunięcie kilku snapshotów
+	inodes map[inodeKey]*inodeUsage
+}
+
+// Typ inodeKey identyfikuje i-węzeł.
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+// Typ inodeUsage opisuje jeden i-węzeł i snapshoty, które go używają.
+// Snapshoty nie muszą tworzyć ciągłego przedziału - np. snapshot
+// przypięty albo połączony przez fsck -repair może współdzielić
+// i-węzeł z dużo starszym snapshotem.
+type inodeUsage struct {
+	size  int64  // bajty zajęte na dysku
+	snaps []int  // rosnące indeksy snapshotów używających i-węzła
+	links uint64 // liczba linków znalezionych we wszystkich snapshotach
+	nlink uint64 // liczba wszystkich linków i-węzła
+}
+
+// DiskUsage oblicza zajętość miejsca przez snapshoty w katalogu dst.
+// Każdy snapshot jest przeglądany jeden raz. Miejsce zajęte przez
+// i-węzeł jest przypisywane do snapshotu jako wyłączne jeśli żaden
+// inny snapshot go nie używa; usunięcie snapshotu zwalnia to miejsce
+// tylko wtedy, gdy i-węzeł nie ma dodatkowych linków poza
+// snapshotami.
+func DiskUsage(dst string) (*Usage, error) {
+	names, err := listSnapshots(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &Usage{
+		Snapshots: make([]SnapshotUsage, len(names)),
+		inodes:    make(map[inodeKey]*inodeUsage),
+	}
+	referenced := make([]int64, len(names))
+
+	for i, name := range names {
+		u.Snapshots[i].Snapshot = name
+		dir := filepath.Join(dst, name)
+		err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			st, ok := fi.Sys().(*syscall.Stat_t)
+			if !ok {
+				return fmt.Errorf("%s: brak informacji o i-węźle", path)
+			}
+			key := inodeKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+			in := u.inodes[key]
+			if in == nil {
+				nlink := uint64(st.Nlink)
+				if fi.IsDir() {
+					// linki katalogu to jego podkatalogi
+					nlink = 1
+				}
+				in = &inodeUsage{
+					size:  int64(st.Blocks) * 512,
+					nlink: nlink,
+				}
+				u.inodes[key] = in
+			}
+			in.links++
+			if n := len(in.snaps); n == 0 || in.snaps[n-1] != i {
+				// pierwsze wystąpienie i-węzła w tym snapshocie
+				in.snaps = append(in.snaps, i)
+				referenced[i] += in.size
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, in := range u.inodes {
+		u.Total += in.size
+		if len(in.snaps) != 1 {
+			continue
+		}
+		s := &u.Snapshots[in.snaps[0]]
+		s.Exclusive += in.size
+		if in.links >= in.nlink {
+			s.Freed += in.size
+		}
+	}
+	for i := range u.Snapshots {
+		s := &u.Snapshots[i]
+		s.Shared = referenced[i] - s.Exclusive
+	}
+	return u, nil
+}
+
+// Freed zwraca przewidywane miejsce zwolnione po usunięciu wszystkich
+// snapshotów names. I-węzeł jest zwalniany jeśli wszystkie snapshoty,
+// które go używają, są usuwane i nie ma on linków poza snapshotami.
+func (u *Usage) Freed(names []string) int64 {
+	index := make(map[string]int)
+	for i, s := range u.Snapshots {
+		index[s.Snapshot] = i
+	}
+	del := make([]bool, len(u.Snapshots))
+	for _, name := range names {
+		if i, ok := index[name]; ok {
+			del[i] = true
+		}
+	}
+
+	var freed int64
+inodes:
+	for _, in := range u.inodes {
+		if in.links < in.nlink {
+			continue
+		}
+		for _, i := range in.snaps {
+			if !del[i] {
+				continue inodes
+			}
+		}
+		freed += in.size
+	}
+	return freed
+}
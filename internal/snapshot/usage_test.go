@@ -0,0 +1,101 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	dst := t.TempDir()
+	a, b, c := "2015-02-10T18:07:39", "2015-02-11T18:07:39", "2015-02-12T18:07:39"
+	for _, name := range []string{a, b, c} {
+		err := os.Mkdir(filepath.Join(dst, name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := func(name, file string, size int) string {
+		path := filepath.Join(dst, name, file)
+		err := os.WriteFile(path, make([]byte, size), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	link := func(path, name string) {
+		err := os.Link(path, filepath.Join(dst, name, filepath.Base(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocks := func(path string) int64 {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int64(fi.Sys().(*syscall.Stat_t).Blocks) * 512
+	}
+
+	// f1 tylko w a; f2 we wszystkich; f3 w a i c, ale nie w b
+	// (np. po fsck -repair); f4 tylko w b, ale z linkiem poza
+	// snapshotami
+	f1 := file(a, "f1", 10000)
+	f2 := file(a, "f2", 20000)
+	link(f2, b)
+	link(f2, c)
+	f3 := file(a, "f3", 30000)
+	link(f3, c)
+	f4 := file(b, "f4", 40000)
+	err := os.Link(f4, filepath.Join(dst, "f4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := func(name string) int64 { return blocks(filepath.Join(dst, name)) }
+	size1, size2, size3, size4 := blocks(f1), blocks(f2), blocks(f3), blocks(f4)
+
+	u, err := DiskUsage(dst)
+	if err != nil {
+		t.Fatalf("DiskUsage: %s", err)
+	}
+	if want := dir(a) + dir(b) + dir(c) + size1 + size2 + size3 + size4; u.Total != want {
+		t.Errorf("Total = %d, oczekiwane %d", u.Total, want)
+	}
+	want := []SnapshotUsage{
+		{Snapshot: a, Exclusive: dir(a) + size1, Shared: size2 + size3, Freed: dir(a) + size1},
+		{Snapshot: b, Exclusive: dir(b) + size4, Shared: size2, Freed: dir(b)},
+		{Snapshot: c, Exclusive: dir(c), Shared: size2 + size3, Freed: dir(c)},
+	}
+	if len(u.Snapshots) != len(want) {
+		t.Fatalf("Snapshots = %+v, oczekiwane %+v", u.Snapshots, want)
+	}
+	for i := range want {
+		if u.Snapshots[i] != want[i] {
+			t.Errorf("Snapshots[%d] = %+v, oczekiwane %+v", i, u.Snapshots[i], want[i])
+		}
+	}
+
+	var tests = []struct {
+		names []string
+		freed int64
+	}{
+		{nil, 0},
+		{[]string{a}, dir(a) + size1},
+		{[]string{b}, dir(b)},
+		{[]string{a, b}, dir(a) + dir(b) + size1},
+		// przedział niespójny: f3 jest zwalniany bez b
+		{[]string{a, c}, dir(a) + dir(c) + size1 + size3},
+		{[]string{c, a, b}, dir(a) + dir(b) + dir(c) + size1 + size2 + size3},
+		{[]string{"2015-02-13T18:07:39"}, 0},
+	}
+	for _, test := range tests {
+		freed := u.Freed(test.names)
+		if freed != test.freed {
+			t.Errorf("Freed(%q) = %d, oczekiwane %d", test.names, freed, test.freed)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// 2026-10-18 adbr
+
+package snapshot
+
+import (
+	"strings"
+	"syscall"
+)
+
+// xattrs zwraca rozszerzone atrybuty pliku path. Nie jest wywoływana
+// dla symlinków, bo syscall.Listxattr podąża za symlinkiem.
+func xattrs(path string) (map[string]string, error) {
+	n, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, n)
+	n, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string]string)
+	for _, name := range strings.Split(string(buf[:n]), "\x00") {
+		if name == "" {
+			continue
+		}
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		val := make([]byte, size)
+		size, err = syscall.Getxattr(path, name, val)
+		if err != nil {
+			return nil, err
+		}
+		attrs[name] = string(val[:size])
+	}
+	return attrs, nil
+}
+
+// setXattrs ustawia rozszerzone atrybuty attrs pliku path.
+func setXattrs(path string, attrs map[string]string) error {
+	for name, val := range attrs {
+		err := syscall.Setxattr(path, name, []byte(val), 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
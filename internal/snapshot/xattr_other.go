@@ -0,0 +1,17 @@
+// 2026-10-18 adbr
+
+//go:build !linux
+
+package snapshot
+
+// xattrs zwraca rozszerzone atrybuty pliku path. Na tym systemie
+// rozszerzone atrybuty nie są obsługiwane.
+func xattrs(path string) (map[string]string, error) {
+	return nil, nil
+}
+
+// setXattrs ustawia rozszerzone atrybuty attrs pliku path. Na tym
+// systemie rozszerzone atrybuty nie są obsługiwane.
+func setXattrs(path string, attrs map[string]string) error {
+	return nil
+}